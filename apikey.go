@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anilsaini81155/spacevoyagers/db"
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+)
+
+const apiKeyUsage = "usage: spacevoyagers apikey create -name NAME -role viewer|editor|admin | list | revoke ID"
+
+// seedBootstrapKey registers the configured bootstrap admin key, the only way
+// to authenticate as an admin with the memory driver
+func seedBootstrapKey(ctx context.Context, keys repository.APIKeyRepository, secret string) error {
+	key := models.APIKeyFor("bootstrap", models.RoleAdmin, secret)
+	return keys.Create(ctx, &key)
+}
+
+// runAPIKey implements the `apikey` subcommand, which manages API keys
+// directly in the database, e.g. to issue the first admin key
+func runAPIKey(args []string) error {
+	if len(args) == 0 {
+		return errors.New(apiKeyUsage)
+	}
+	if db.Driver() == db.DriverMemory {
+		return errors.New("API keys issued with the memory driver would be lost when the command exits")
+	}
+
+	repos, err := repository.Open(db.Driver())
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		name := flags.String("name", "", "name of the key's holder")
+		role := flags.String("role", string(models.RoleViewer), "viewer, editor or admin")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		key, secret, err := models.NewAPIKey(*name, models.Role(*role))
+		if err != nil {
+			return err
+		}
+		if err := key.Validate(); err != nil {
+			return err
+		}
+		if err := repos.APIKeys.Create(ctx, &key); err != nil {
+			return err
+		}
+		fmt.Printf("Issued %s key %d for %s. Store it now, it will not be shown again:\n%s\n", key.Role, key.ID, key.Name, secret)
+		return nil
+
+	case "list":
+		keys, err := repos.APIKeys.List(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPREFIX\tROLE\tCREATED\tREVOKED")
+		for _, key := range keys {
+			revoked := "-"
+			if key.Revoked() {
+				revoked = key.RevokedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix, key.Role, key.CreatedAt.Format(time.RFC3339), revoked)
+		}
+		return w.Flush()
+
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New(apiKeyUsage)
+		}
+		var id int
+		if _, err := fmt.Sscan(args[1], &id); err != nil {
+			return errors.New(apiKeyUsage)
+		}
+		if err := repos.APIKeys.Revoke(ctx, id); err != nil {
+			return err
+		}
+		fmt.Printf("Revoked key %d\n", id)
+		return nil
+	}
+	return errors.New(apiKeyUsage)
+}
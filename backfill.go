@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/anilsaini81155/spacevoyagers/db"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+)
+
+const backfillUsage = "usage: spacevoyagers backfill gravity"
+
+// runBackfill implements the `backfill` subcommand, which recomputes derived
+// columns for rows written before they existed
+func runBackfill(args []string) error {
+	if len(args) != 1 || args[0] != "gravity" {
+		return errors.New(backfillUsage)
+	}
+
+	repos, err := repository.Open(db.Driver())
+	if err != nil {
+		return err
+	}
+
+	backfiller, ok := repos.Exoplanets.(repository.GravityBackfiller)
+	if !ok {
+		return errors.New("the configured driver computes gravity on write and needs no backfill")
+	}
+
+	updated, err := backfiller.BackfillGravity(context.Background())
+	if err != nil {
+		return err
+	}
+	log.Printf("Backfilled gravity for %d exoplanets", updated)
+	return nil
+}
@@ -0,0 +1,279 @@
+// Package config loads the service configuration in layers, each overriding
+// the previous one:
+//
+//  1. built-in defaults
+//  2. the YAML file given by --config or SPACEVOYAGERS_CONFIG, or config.yaml
+//     when present, followed by config.<profile>.yaml
+//  3. the .env<profile> and .env files (e.g. .envtest for the "test" profile)
+//  4. environment variables
+//  5. command-line flags such as --server.port=8081
+//
+// The profile comes from --profile, SPACEVOYAGERS_PROFILE or GO_ENV.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/anilsaini81155/spacevoyagers/db"
+	"github.com/anilsaini81155/spacevoyagers/logging"
+	"github.com/anilsaini81155/spacevoyagers/middleware"
+	"github.com/anilsaini81155/spacevoyagers/models"
+)
+
+// Config is the effective configuration of the service. The yaml tag names a
+// field in the config file and, joined with its section, its flag; the env
+// tag names its environment variable. Fields tagged secret are redacted by
+// Redacted.
+type Config struct {
+	// Profile is the environment the configuration was loaded for, e.g. "test"
+	Profile string `yaml:"profile"`
+
+	Server    Server    `yaml:"server"`
+	Database  Database  `yaml:"database"`
+	Log       Log       `yaml:"log"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Auth      Auth      `yaml:"auth"`
+	CORS      CORS      `yaml:"cors"`
+	Tracing   Tracing   `yaml:"tracing"`
+}
+
+// Server configures the HTTP server
+type Server struct {
+	Port              int           `yaml:"port" env:"APP_PORT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	ReadinessTimeout  time.Duration `yaml:"readiness_timeout" env:"READINESS_TIMEOUT"`
+}
+
+// Database selects and configures the storage backend
+type Database struct {
+	// Driver is mysql, sqlite or memory
+	Driver     string `yaml:"driver" env:"DB_DRIVER"`
+	Host       string `yaml:"host" env:"DB_HOST"`
+	Port       int    `yaml:"port" env:"DB_PORT"`
+	User       string `yaml:"user" env:"DB_USER"`
+	Password   string `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name       string `yaml:"name" env:"DB_NAME"`
+	SQLitePath string `yaml:"sqlite_path" env:"SQLITE_PATH"`
+	// AutoMigrate applies pending migrations when the server starts. It is
+	// off by default so that rollouts are driven by the migrate command.
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+}
+
+// Log configures the structured logger
+type Log struct {
+	// Level is debug, info, warn or error
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+}
+
+// RateLimit configures the per-client rate limits. Each group is a policy
+// such as "60/1m:10", or "off".
+type RateLimit struct {
+	// Store is memory (per process) or redis (shared by every replica)
+	Store      string `yaml:"store" env:"RATE_LIMIT_STORE"`
+	MaxClients int    `yaml:"max_clients" env:"RATE_LIMIT_MAX_CLIENTS"`
+	RedisURL   string `yaml:"redis_url" env:"REDIS_URL" secret:"url"`
+	List       string `yaml:"list" env:"RATE_LIMIT_LIST"`
+	Read       string `yaml:"read" env:"RATE_LIMIT_READ"`
+	Write      string `yaml:"write" env:"RATE_LIMIT_WRITE"`
+	Plan       string `yaml:"plan" env:"RATE_LIMIT_PLAN"`
+	// Auth limits requests with credentials per IP before their key is looked up
+	Auth string `yaml:"auth" env:"RATE_LIMIT_AUTH"`
+}
+
+// Groups returns the policy of each group keyed by its name, e.g. "list"
+func (r RateLimit) Groups() map[string]string {
+	return map[string]string{"list": r.List, "read": r.Read, "write": r.Write, "plan": r.Plan, "auth": r.Auth}
+}
+
+// minBootstrapKeyLength keeps bootstrap keys from being guessable
+const minBootstrapKeyLength = 24
+
+// Auth configures API key and JWT authentication
+type Auth struct {
+	// AnonymousRole is given to requests without credentials; empty requires
+	// credentials everywhere
+	AnonymousRole string `yaml:"anonymous_role" env:"AUTH_ANONYMOUS_ROLE"`
+	// BootstrapKey is an admin API key registered at startup with the memory
+	// driver, which cannot issue keys from the command line
+	BootstrapKey string `yaml:"bootstrap_key" env:"AUTH_BOOTSTRAP_KEY" secret:"true"`
+	// JWKS is a JWKS file path or URL; empty disables JWTs
+	JWKS        string        `yaml:"jwks" env:"JWT_JWKS"`
+	Issuer      string        `yaml:"issuer" env:"JWT_ISSUER"`
+	Audience    string        `yaml:"audience" env:"JWT_AUDIENCE"`
+	ClockSkew   time.Duration `yaml:"clock_skew" env:"JWT_CLOCK_SKEW"`
+	JWKSRefresh time.Duration `yaml:"jwks_refresh" env:"JWT_JWKS_REFRESH"`
+}
+
+// CORS is the cross-origin policy; see middleware.CORSConfig
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   []string      `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE"`
+}
+
+// Tracing configures the OpenTelemetry exporter
+type Tracing struct {
+	// Exporter is otlp, stdout or none
+	Exporter string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+	File     string `yaml:"file" env:"OTEL_TRACES_FILE"`
+}
+
+// Defaults returns the configuration used when nothing overrides it
+func Defaults() Config {
+	return Config{
+		Server: Server{
+			Port:              8080,
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+			ReadinessTimeout:  2 * time.Second,
+		},
+		Database: Database{
+			Driver:     db.DriverMySQL,
+			Host:       "localhost",
+			Port:       3306,
+			Name:       "exoplanets",
+			SQLitePath: "spacevoyagers.db",
+		},
+		Log: Log{Level: "info"},
+		RateLimit: RateLimit{
+			Store:      "memory",
+			MaxClients: 10000,
+			List:       "30/1m:10",
+			Read:       "120/1m:30",
+			Write:      "30/1m:10",
+			Plan:       "10/1m:5",
+			Auth:       "300/1m:60",
+		},
+		Auth: Auth{
+			ClockSkew:   time.Minute,
+			JWKSRefresh: 15 * time.Minute,
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID", "If-Match", "If-None-Match"},
+			ExposedHeaders: []string{"ETag", "Link", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Policy", "X-RateLimit-Remaining", "X-Request-ID"},
+			MaxAge:         10 * time.Minute,
+		},
+		Tracing: Tracing{Exporter: "none"},
+	}
+}
+
+// Settings returns the connection parameters in the form package db takes
+func (d Database) Settings() db.Settings {
+	return db.Settings{
+		Driver:     d.Driver,
+		Host:       d.Host,
+		Port:       d.Port,
+		User:       d.User,
+		Password:   d.Password,
+		Name:       d.Name,
+		SQLitePath: d.SQLitePath,
+	}
+}
+
+// CORSConfig returns the CORS policy in the form the middleware takes
+func (c Config) CORSConfig() middleware.CORSConfig {
+	return middleware.CORSConfig{
+		AllowedOrigins:   c.CORS.AllowedOrigins,
+		AllowedMethods:   c.CORS.AllowedMethods,
+		AllowedHeaders:   c.CORS.AllowedHeaders,
+		ExposedHeaders:   c.CORS.ExposedHeaders,
+		AllowCredentials: c.CORS.AllowCredentials,
+		MaxAge:           c.CORS.MaxAge,
+	}
+}
+
+// Validate reports every invalid setting at once, naming each by its
+// environment variable
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "APP_PORT must be between 1 and 65535")
+	for _, setting := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"SERVER_READ_TIMEOUT", c.Server.ReadTimeout},
+		{"SERVER_READ_HEADER_TIMEOUT", c.Server.ReadHeaderTimeout},
+		{"SERVER_WRITE_TIMEOUT", c.Server.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", c.Server.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout},
+		{"READINESS_TIMEOUT", c.Server.ReadinessTimeout},
+		{"JWT_JWKS_REFRESH", c.Auth.JWKSRefresh},
+	} {
+		check(setting.value > 0, "%s must be a positive duration such as 30s", setting.name)
+	}
+
+	switch c.Database.Driver {
+	case db.DriverMySQL:
+		check(c.Database.Host != "", "DB_HOST is required for the mysql driver")
+		check(c.Database.Name != "", "DB_NAME is required for the mysql driver")
+		check(c.Database.Port > 0 && c.Database.Port < 65536, "DB_PORT must be between 1 and 65535")
+	case db.DriverSQLite:
+		check(c.Database.SQLitePath != "", "SQLITE_PATH is required for the sqlite driver")
+	case db.DriverMemory:
+	default:
+		check(false, "DB_DRIVER must be mysql, sqlite or memory")
+	}
+
+	_, err := logging.ParseLevel(c.Log.Level)
+	check(err == nil, "LOG_LEVEL must be debug, info, warn or error")
+
+	switch c.RateLimit.Store {
+	case "memory":
+		check(c.RateLimit.MaxClients > 0, "RATE_LIMIT_MAX_CLIENTS must be a positive integer")
+	case "redis":
+		check(c.RateLimit.RedisURL != "", "REDIS_URL is required for the redis rate limit store")
+	default:
+		check(false, "RATE_LIMIT_STORE must be memory or redis")
+	}
+	groups := c.RateLimit.Groups()
+	for _, group := range slices.Sorted(maps.Keys(groups)) {
+		if spec := groups[group]; !strings.EqualFold(spec, "off") {
+			_, err := middleware.ParsePolicy(spec)
+			check(err == nil, "RATE_LIMIT_%s: %v", strings.ToUpper(group), err)
+		}
+	}
+
+	role := models.Role(c.Auth.AnonymousRole)
+	check(role == "" || role.Valid(), "AUTH_ANONYMOUS_ROLE must be viewer, editor or admin")
+	check(c.Auth.BootstrapKey == "" || c.Database.Driver == db.DriverMemory,
+		"AUTH_BOOTSTRAP_KEY is only used with the memory driver; issue keys with the apikey command")
+	check(c.Auth.BootstrapKey == "" || (strings.HasPrefix(c.Auth.BootstrapKey, models.APIKeyPrefix) && len(c.Auth.BootstrapKey) >= minBootstrapKeyLength),
+		"AUTH_BOOTSTRAP_KEY must start with %s and be at least %d characters long", models.APIKeyPrefix, minBootstrapKeyLength)
+	check(c.Auth.ClockSkew >= 0, "JWT_CLOCK_SKEW must not be negative")
+
+	check(c.CORS.MaxAge >= 0, "CORS_MAX_AGE must not be negative")
+	if err := c.CORSConfig().Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		check(false, "OTEL_TRACES_EXPORTER must be otlp, stdout or none")
+	}
+
+	return errors.Join(errs...)
+}
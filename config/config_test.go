@@ -0,0 +1,121 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inDir runs the test from a directory holding the given files
+func inDir(t *testing.T, files map[string]string) {
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// unsetenv unsets environment variables for the duration of the test
+func unsetenv(t *testing.T, names ...string) {
+	for _, name := range names {
+		t.Setenv(name, "") // restores the variable after the test
+		require.NoError(t, os.Unsetenv(name))
+	}
+}
+
+// TestLoadLayers checks that each layer overrides the previous ones
+func TestLoadLayers(t *testing.T) {
+	inDir(t, map[string]string{
+		"config.yaml": `
+server:
+  port: 8001
+  shutdown_timeout: 45s
+log:
+  level: debug
+auth:
+  anonymous_role: viewer
+  jwks: jwks.json
+database:
+  driver: sqlite
+  sqlite_path: file.db
+`,
+		"config.test.yaml": `
+server:
+  port: 8002
+rate_limit:
+  list: "off"
+`,
+		".env":     "LOG_LEVEL=warn\nSQLITE_PATH=dotenv.db\nDB_PASSWORD=hunter2\nREDIS_URL=redis://:s3cret@cache:6379/0\nDB_USER=base\n",
+		".envtest": "SQLITE_PATH=profile.db\nRATE_LIMIT_READ=5/1s\nDB_USER=voyager\n",
+	})
+	unsetenv(t, "GO_ENV", "SPACEVOYAGERS_PROFILE", "SPACEVOYAGERS_CONFIG", "LOG_LEVEL", "DB_PASSWORD", "REDIS_URL", "APP_PORT", "DB_DRIVER", "DB_USER", "AUTH_ANONYMOUS_ROLE", "JWT_JWKS")
+	t.Setenv("SQLITE_PATH", "env.db")
+	t.Setenv("RATE_LIMIT_READ", "7/1s")
+	t.Setenv("AUTH_ANONYMOUS_ROLE", "") // set but empty still overrides
+
+	cfg, args, err := Load([]string{"--profile=test", "--database.sqlite_path=flag.db", "migrate", "up"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"migrate", "up"}, args)
+	assert.Equal(t, "test", cfg.Profile)
+	assert.Equal(t, 8002, cfg.Server.Port)                      // profile file over config file
+	assert.Equal(t, 45*time.Second, cfg.Server.ShutdownTimeout) // config file over defaults
+	assert.Equal(t, 60*time.Second, cfg.Server.IdleTimeout)     // defaults
+	assert.Equal(t, "off", cfg.RateLimit.List)                  // profile file
+	assert.Equal(t, "warn", cfg.Log.Level)                      // .env over config file
+	assert.Equal(t, "voyager", cfg.Database.User)               // .envtest over .env
+	assert.Equal(t, "7/1s", cfg.RateLimit.Read)                 // environment over .envtest
+	assert.Equal(t, "flag.db", cfg.Database.SQLitePath)         // flags over everything
+	assert.Equal(t, "", cfg.Auth.AnonymousRole)                 // empty environment variable over config file
+	assert.Equal(t, "jwks.json", cfg.Auth.JWKS)                 // config file, unset in the environment
+	assert.Equal(t, []string{"*"}, cfg.CORS.AllowedOrigins)     // defaults
+	assert.Equal(t, "hunter2", cfg.Database.Password)           // .env
+	assert.Equal(t, "redis://:s3cret@cache:6379/0", cfg.RateLimit.RedisURL)
+
+	// Secrets are redacted from config show, and the original is untouched
+	out, err := cfg.YAML()
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "hunter2")
+	assert.NotContains(t, string(out), "s3cret")
+	assert.Contains(t, string(out), "redis://:xxxxx@cache:6379/0")
+	assert.Contains(t, string(out), "shutdown_timeout: 45s")
+	assert.Equal(t, "hunter2", cfg.Database.Password)
+}
+
+// TestLoadRejectsInvalidConfig checks that every invalid setting is reported
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	inDir(t, map[string]string{"config.yaml": "server:\n  prot: 8080\n"})
+	unsetenv(t, "GO_ENV", "SPACEVOYAGERS_PROFILE", "SPACEVOYAGERS_CONFIG")
+
+	// Unknown fields in the file are typos
+	_, _, err := Load(nil)
+	assert.ErrorContains(t, err, "prot")
+
+	require.NoError(t, os.Remove("config.yaml"))
+	t.Setenv("APP_PORT", "70000")
+	t.Setenv("DB_DRIVER", "postgres")
+	t.Setenv("RATE_LIMIT_PLAN", "lots")
+	t.Setenv("AUTH_BOOTSTRAP_KEY", "sv_short")
+	_, _, err = Load([]string{"--cors.allow_credentials=true"})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "APP_PORT")
+	assert.ErrorContains(t, err, "DB_DRIVER")
+	assert.ErrorContains(t, err, "RATE_LIMIT_PLAN")
+	assert.ErrorContains(t, err, "credentials")
+	assert.ErrorContains(t, err, "AUTH_BOOTSTRAP_KEY must start with sv_")
+	assert.ErrorContains(t, err, "AUTH_BOOTSTRAP_KEY is only used with the memory driver")
+
+	// A config file that is named must exist
+	unsetenv(t, "APP_PORT", "DB_DRIVER", "RATE_LIMIT_PLAN", "AUTH_BOOTSTRAP_KEY")
+	_, _, err = Load([]string{"--config=missing.yaml"})
+	assert.Error(t, err)
+
+	assert.NoError(t, Defaults().Validate())
+}
@@ -0,0 +1,294 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultFile is read when no config file is named, if it exists
+const defaultFile = "config.yaml"
+
+// redacted replaces the value of secrets in Redacted
+const redacted = "REDACTED"
+
+// Load builds the configuration from every layer and validates it. args are
+// the command-line arguments without the program name; the arguments left
+// after the flags, such as a subcommand, are returned.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Defaults()
+
+	// Flags are parsed first to learn the profile and config file, but
+	// applied last so that they override every other layer
+	flags := flag.NewFlagSet("spacevoyagers", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML config file (default "+defaultFile+" when present)")
+	profile := flags.String("profile", "", "configuration profile, e.g. test or production")
+	type override struct {
+		name, value string
+		field       reflect.Value
+	}
+	var overrides []override
+	walk(&cfg, func(name, env string, field reflect.Value, _ reflect.StructTag) {
+		flags.Func(name, "overrides "+env, func(value string) error {
+			overrides = append(overrides, override{name: name, value: value, field: field})
+			return nil
+		})
+	})
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	dotenv, err := readEnvFiles(profileName(*profile))
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.Profile = profileName(*profile, dotenv["GO_ENV"])
+
+	if err := readConfigFiles(&cfg, *configFile); err != nil {
+		return nil, nil, err
+	}
+
+	var errs []error
+	walk(&cfg, func(name, env string, field reflect.Value, _ reflect.StructTag) {
+		// The environment overrides .env files. A variable that is set but
+		// empty still overrides, clearing the setting; unset ones are skipped.
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			value, ok = dotenv[env]
+		}
+		if ok {
+			if err := set(field, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", env, err))
+			}
+		}
+	})
+	for _, o := range overrides {
+		if err := set(o.field, o.value); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", o.name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	// Other values from .env files are exported to the process environment
+	// for libraries that read it directly, such as the OTLP exporter's
+	// OTEL_EXPORTER_OTLP_* variables
+	for name, value := range dotenv {
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, value)
+		}
+	}
+	return &cfg, flags.Args(), nil
+}
+
+// profileName returns the first profile named by the --profile flag, the
+// SPACEVOYAGERS_PROFILE or GO_ENV environment variables, or fallbacks
+func profileName(flagValue string, fallbacks ...string) string {
+	candidates := append([]string{flagValue, os.Getenv("SPACEVOYAGERS_PROFILE"), os.Getenv("GO_ENV")}, fallbacks...)
+	for _, candidate := range candidates {
+		if candidate != "" {
+			return strings.ToLower(candidate)
+		}
+	}
+	return ""
+}
+
+// readEnvFiles reads .env and, when a profile is known, .env<profile> over
+// it. Missing files are skipped.
+func readEnvFiles(profile string) (map[string]string, error) {
+	values, err := readEnvFile(".env")
+	if err != nil {
+		return nil, err
+	}
+	if profile == "" {
+		profile = strings.ToLower(values["GO_ENV"])
+	}
+	if profile != "" {
+		overlay, err := readEnvFile(".env" + profile)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range overlay {
+			values[name] = value
+		}
+	}
+	return values, nil
+}
+
+// readEnvFile parses a dotenv file, returning no values when it is missing
+func readEnvFile(path string) (map[string]string, error) {
+	values, err := godotenv.Read(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// readConfigFiles decodes the config file, then its profile variant, e.g.
+// config.test.yaml, into cfg. A file named by --config or SPACEVOYAGERS_CONFIG
+// must exist; the default file and profile variants are optional.
+func readConfigFiles(cfg *Config, path string) error {
+	if path == "" {
+		path = os.Getenv("SPACEVOYAGERS_CONFIG")
+	}
+	required := path != ""
+	if path == "" {
+		path = defaultFile
+	}
+
+	if err := readConfigFile(cfg, path, required); err != nil {
+		return err
+	}
+	if cfg.Profile == "" {
+		return nil
+	}
+	ext := filepath.Ext(path)
+	return readConfigFile(cfg, strings.TrimSuffix(path, ext)+"."+cfg.Profile+ext, false)
+}
+
+// readConfigFile decodes one YAML file into cfg, keeping the fields it does
+// not mention. Unknown fields are errors, to catch typos.
+func readConfigFile(cfg *Config, path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	profile := cfg.Profile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	// The profile selects the files, so a file cannot change it
+	cfg.Profile = profile
+	return nil
+}
+
+// walk calls fn for every setting of cfg with its flag name (e.g.
+// "server.port"), environment variable and settable field
+func walk(cfg *Config, fn func(name, env string, field reflect.Value, tag reflect.StructTag)) {
+	var visit func(v reflect.Value, prefix string)
+	visit = func(v reflect.Value, prefix string) {
+		for i := 0; i < v.NumField(); i++ {
+			structField := v.Type().Field(i)
+			name := prefix + structField.Tag.Get("yaml")
+			if structField.Type.Kind() == reflect.Struct {
+				visit(v.Field(i), name+".")
+				continue
+			}
+			if env := structField.Tag.Get("env"); env != "" {
+				fn(name, env, v.Field(i), structField.Tag)
+			}
+		}
+	}
+	visit(reflect.ValueOf(cfg).Elem(), "")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses value into field according to its type. Lists are
+// comma-separated; an empty value resets the field to its zero value.
+func set(field reflect.Value, value string) error {
+	if value == "" {
+		field.SetZero()
+		return nil
+	}
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// Redacted returns a copy of c with secrets hidden. Secret URLs keep
+// everything but their password.
+func (c Config) Redacted() Config {
+	// Copy the slices too, so that the copy shares nothing with c
+	c.CORS.AllowedOrigins = append([]string(nil), c.CORS.AllowedOrigins...)
+	c.CORS.AllowedMethods = append([]string(nil), c.CORS.AllowedMethods...)
+	c.CORS.AllowedHeaders = append([]string(nil), c.CORS.AllowedHeaders...)
+	c.CORS.ExposedHeaders = append([]string(nil), c.CORS.ExposedHeaders...)
+
+	walk(&c, func(_, _ string, field reflect.Value, tag reflect.StructTag) {
+		value := field.String()
+		switch tag.Get("secret") {
+		case "true":
+			if value != "" {
+				field.SetString(redacted)
+			}
+		case "url":
+			if u, err := url.Parse(value); err == nil {
+				field.SetString(u.Redacted())
+			} else {
+				field.SetString(redacted)
+			}
+		}
+	})
+	return c
+}
+
+// YAML renders c, with secrets redacted, in the config file format
+func (c Config) YAML() ([]byte, error) {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), encoder.Close()
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/anilsaini81155/spacevoyagers/config"
+)
+
+const configUsage = "usage: spacevoyagers [--profile NAME] [--config FILE] config show"
+
+// runConfig implements the `config` subcommand, which prints the effective
+// configuration with secrets redacted
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "show" {
+		return errors.New(configUsage)
+	}
+
+	out, err := cfg.YAML()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
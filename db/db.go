@@ -3,49 +3,74 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
 	"sync"
 
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
 	_ "github.com/go-sql-driver/mysql" // MySQL driver
+	_ "modernc.org/sqlite"             // SQLite driver (pure Go, no cgo)
+)
+
+// Supported values for the DB_DRIVER environment variable
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+	DriverMemory = "memory"
 )
 
+// Settings are the connection parameters of the database
+type Settings struct {
+	Driver     string
+	Host       string
+	Port       int
+	User       string
+	Password   string
+	Name       string
+	SQLitePath string
+}
+
 var (
 	once     sync.Once
 	instance *sql.DB
 	dbErr    error
+	settings = Settings{Driver: DriverMySQL}
 )
 
-// GetDB returns the singleton instance of the database connection
+// Configure sets the connection parameters; call it before GetDB
+func Configure(s Settings) {
+	settings = s
+}
+
+// Driver returns the configured storage driver, defaulting to MySQL
+func Driver() string {
+	if settings.Driver == "" {
+		return DriverMySQL
+	}
+	return settings.Driver
+}
+
+// GetDB returns the singleton instance of the database connection. The pool
+// connects lazily and reconnects on its own, so an unreachable database is
+// not an error here: callers find out from their queries, and readiness from
+// a ping. Only invalid settings are returned, and they are permanent.
 func GetDB() (*sql.DB, error) {
 	once.Do(func() {
 
-		// Get database connection parameters from environment variables
-		dbHost := os.Getenv("DB_HOST")
-		dbPort := os.Getenv("DB_PORT")
-		dbUser := os.Getenv("DB_USER")
-		dbPassword := os.Getenv("DB_PASSWORD")
-		dbName := os.Getenv("DB_NAME")
+		var db *sql.DB
+		var err error
 
-		// Build the DSN (Data Source Name)
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", dbUser, dbPassword, dbHost, dbPort, dbName)
-
-		db, err := sql.Open("mysql", dsn)
-		if err != nil {
-			log.Fatalf("Error opening database connection: %v", err)
-			dbErr = err
-			return
+		switch Driver() {
+		case DriverSQLite:
+			db, err = openSQLite()
+		case DriverMySQL:
+			db, err = openMySQL()
+		default:
+			err = fmt.Errorf("driver %q does not use a database connection", Driver())
 		}
-
-		// Set any database configurations (optional)
-		db.SetMaxOpenConns(25)
-		db.SetMaxIdleConns(25)
-		db.SetConnMaxLifetime(5 * 60 * 60) // 5 hours
-
-		// Ping the database to verify connection
-		if err := db.Ping(); err != nil {
-			log.Fatalf("Error connecting to the database: %v", err)
-			dbErr = err
+		if err != nil {
+			dbErr = fmt.Errorf("opening database connection: %w", err)
 			return
 		}
 
@@ -54,3 +79,52 @@ func GetDB() (*sql.DB, error) {
 
 	return instance, dbErr
 }
+
+// openMySQL opens a MySQL connection pool from the configured settings
+func openMySQL() (*sql.DB, error) {
+	// Build the DSN (Data Source Name)
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", settings.User, settings.Password, settings.Host, settings.Port, settings.Name)
+
+	db, err := otelsql.Open("mysql", dsn, traceOptions(semconv.DBSystemMySQL)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set any database configurations (optional)
+	db.SetMaxOpenConns(25)
+	db.SetMaxIdleConns(25)
+	db.SetConnMaxLifetime(5 * 60 * 60) // 5 hours
+
+	return db, nil
+}
+
+// openSQLite opens the embedded SQLite database at the configured path
+func openSQLite() (*sql.DB, error) {
+	path := settings.SQLitePath
+	if path == "" {
+		path = "spacevoyagers.db"
+	}
+
+	db, err := otelsql.Open("sqlite", path, traceOptions(semconv.DBSystemSqlite)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer; serialise access through one connection
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+// traceOptions instruments the driver so that every statement becomes a span,
+// a child of the span in the statement's context
+func traceOptions(system attribute.KeyValue) []otelsql.Option {
+	return []otelsql.Option{
+		otelsql.WithAttributes(system),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	}
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetDBUnreachable checks that an unreachable database does not fail
+// GetDB and that the pool connects once the database is available
+func TestGetDBUnreachable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "not-yet")
+	Configure(Settings{Driver: DriverSQLite, SQLitePath: filepath.Join(dir, "test.db")})
+
+	dbConn, err := GetDB()
+	require.NoError(t, err)
+	defer dbConn.Close()
+	assert.Error(t, dbConn.Ping())
+
+	require.NoError(t, os.Mkdir(dir, 0o700))
+	assert.NoError(t, dbConn.Ping())
+
+	again, err := GetDB()
+	require.NoError(t, err)
+	assert.Same(t, dbConn, again)
+}
@@ -1,10 +1,6 @@
 package factory
 
-import (
-	"errors"
-
-	"github.com/anilsaini81155/spacevoyagers/models"
-)
+import "github.com/anilsaini81155/spacevoyagers/models"
 
 // CreateExoplanet creates an exoplanet based on its type.
 func CreateExoplanet(exoplanetType, name, description string, distance, radius, mass float64) (models.Exoplanet, error) {
@@ -28,6 +24,6 @@ func CreateExoplanet(exoplanetType, name, description string, distance, radius,
 			Type:        "GasGiant",
 		}, nil
 	default:
-		return models.Exoplanet{}, errors.New("unknown exoplanet type")
+		return models.Exoplanet{}, models.InvalidField("type", models.CodeInvalidChoice, "unknown exoplanet type")
 	}
 }
@@ -10,22 +10,25 @@ type Exoplanet interface {
 	GetType() string
 }
 
-// GasGiant struct
+// GasGiant struct, with Distance in light years and Radius and Mass in the
+// canonical storage units, Earth radii and Earth masses
 type GasGiant struct {
 	Name        string
 	Description string
 	Distance    float64
 	Radius      float64
+	Mass        float64
 }
 
 func (g *GasGiant) GetName() string        { return g.Name }
 func (g *GasGiant) GetDescription() string { return g.Description }
 func (g *GasGiant) GetDistance() float64   { return g.Distance }
 func (g *GasGiant) GetRadius() float64     { return g.Radius }
-func (g *GasGiant) GetMass() float64       { return 0 } // Mass is not applicable for GasGiant
+func (g *GasGiant) GetMass() float64       { return g.Mass }
 func (g *GasGiant) GetType() string        { return "GasGiant" }
 
-// Terrestrial struct
+// Terrestrial struct, with Distance in light years and Radius and Mass in
+// Earth radii and Earth masses
 type Terrestrial struct {
 	Name        string
 	Description string
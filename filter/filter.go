@@ -0,0 +1,308 @@
+package filter
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/units"
+)
+
+// Expr is a compiled filter expression. It can be rendered as a parameterized
+// SQL condition for the SQL backends or evaluated directly against an
+// exoplanet for in-memory use.
+type Expr interface {
+	// SQL returns the condition with ? placeholders and its arguments
+	SQL() (string, []interface{})
+	// Match reports whether the exoplanet satisfies the expression
+	Match(exoplanet models.Exoplanet) bool
+}
+
+// Error describes a filter that could not be parsed, naming the offending clause
+type Error struct {
+	Clause string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid filter clause %q: %s", e.Clause, e.Reason)
+}
+
+// kind is the value type of a field
+type kind int
+
+const (
+	number kind = iota
+	text
+)
+
+// field describes a filterable exoplanet attribute
+type field struct {
+	kind   kind
+	column string // SQL expression
+	ops    []string
+	value  func(p models.Exoplanet) interface{}
+	// quantity is set for numbers with a unit, which are compared in
+	// models.StorageUnits
+	quantity *quantity
+}
+
+// quantity describes the unit of a field: unit picks it from a set of Units
+// and convert converts between units of its dimension
+type quantity struct {
+	unit    func(u models.Units) string
+	convert func(value float64, from, to string) (float64, error)
+}
+
+// bareUnit returns the unit of a number given without one in a unit system,
+// or "" when responses show the field in a different unit for each type
+func (q *quantity) bareUnit(system models.UnitSystem) string {
+	unit := q.unit((&models.Exoplanet{Type: models.Terrestrial}).Units(system))
+	if unit != q.unit((&models.Exoplanet{Type: models.GasGiant}).Units(system)) {
+		return ""
+	}
+	return unit
+}
+
+// Quantity converts a number given for field without a unit, in a unit
+// system, into the storage unit Compare takes. It fails when responses show
+// the field in a different unit for each type, as radius and mass in Astro.
+func Quantity(fieldName string, value float64, system models.UnitSystem) (float64, error) {
+	q := fields[fieldName].quantity
+	if q == nil {
+		return value, nil
+	}
+	unit := q.bareUnit(system)
+	if unit == "" {
+		example := q.unit((&models.Exoplanet{Type: models.Terrestrial}).Units(system))
+		return 0, fmt.Errorf("%s is shown in Earth or Jupiter units depending on the type; give a unit, e.g. %s gt 1.5 %s, or use units=si", fieldName, fieldName, example)
+	}
+	return q.convert(value, unit, q.unit(models.StorageUnits))
+}
+
+var numericOps = []string{"eq", "ne", "gt", "ge", "lt", "le", "in"}
+
+// fields lists every attribute that can appear in a filter
+var fields = map[string]field{
+	"id": {kind: number, column: "id", ops: numericOps,
+		value: func(p models.Exoplanet) interface{} { return float64(p.ID) }},
+	"name": {kind: text, column: "name", ops: []string{"eq", "ne", "in", "prefix", "contains"},
+		value: func(p models.Exoplanet) interface{} { return p.Name }},
+	"description": {kind: text, column: "description", ops: []string{"eq", "ne", "contains", "search"},
+		value: func(p models.Exoplanet) interface{} { return p.Description }},
+	"distance": {kind: number, column: "distance", ops: numericOps,
+		value:    func(p models.Exoplanet) interface{} { return p.Distance },
+		quantity: &quantity{unit: func(u models.Units) string { return u.Distance }, convert: units.ConvertLength}},
+	"radius": {kind: number, column: "radius", ops: numericOps,
+		value:    func(p models.Exoplanet) interface{} { return p.Radius },
+		quantity: &quantity{unit: func(u models.Units) string { return u.Radius }, convert: units.ConvertLength}},
+	"mass": {kind: number, column: "mass", ops: numericOps,
+		value:    func(p models.Exoplanet) interface{} { return p.Mass },
+		quantity: &quantity{unit: func(u models.Units) string { return u.Mass }, convert: units.ConvertMass}},
+	"type": {kind: text, column: "type", ops: []string{"eq", "ne", "in"},
+		value: func(p models.Exoplanet) interface{} { return string(p.Type) }},
+	"gravity": {kind: number, column: "gravity", ops: numericOps,
+		value: func(p models.Exoplanet) interface{} { return p.Gravity }},
+}
+
+// sqlOperators maps the comparison keywords to their SQL operators
+var sqlOperators = map[string]string{
+	"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<=",
+}
+
+// comparison tests one field against one or more values
+type comparison struct {
+	field  string
+	op     string
+	values []interface{}
+}
+
+// Compare builds a single comparison, validating the field, operator and
+// value types in the same way as Parse. Numbers for distance, radius and mass
+// are in models.StorageUnits.
+func Compare(fieldName, op string, values ...interface{}) (Expr, error) {
+	clause := fieldName + " " + op
+	f, ok := fields[fieldName]
+	if !ok {
+		return nil, &Error{Clause: clause, Reason: fmt.Sprintf("unknown field %q", fieldName)}
+	}
+	if !slices.Contains(f.ops, op) {
+		return nil, &Error{Clause: clause, Reason: fmt.Sprintf("operator %q is not supported for %s", op, fieldName)}
+	}
+	if len(values) == 0 || (op != "in" && len(values) > 1) {
+		return nil, &Error{Clause: clause, Reason: "wrong number of values"}
+	}
+	for _, value := range values {
+		switch value.(type) {
+		case float64:
+			if f.kind != number {
+				return nil, &Error{Clause: clause, Reason: fmt.Sprintf("%s expects a quoted string", fieldName)}
+			}
+		case string:
+			if f.kind != text {
+				return nil, &Error{Clause: clause, Reason: fmt.Sprintf("%s expects a number", fieldName)}
+			}
+		default:
+			return nil, &Error{Clause: clause, Reason: fmt.Sprintf("unsupported value %v", value)}
+		}
+	}
+	return &comparison{field: fieldName, op: op, values: values}, nil
+}
+
+func (c *comparison) SQL() (string, []interface{}) {
+	column := fields[c.field].column
+	values := c.values
+	if fields[c.field].kind == text && (c.op == "eq" || c.op == "ne" || c.op == "in") {
+		// Text comparisons ignore case whatever the collation, as in Match
+		column = "LOWER(" + column + ")"
+		values = make([]interface{}, len(c.values))
+		for i, value := range c.values {
+			values[i] = strings.ToLower(value.(string))
+		}
+	}
+
+	switch c.op {
+	case "in":
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		return column + " IN (" + placeholders + ")", values
+	case "prefix":
+		return column + ` LIKE ? ESCAPE '!'`, []interface{}{escapeLike(c.values[0].(string)) + "%"}
+	case "contains":
+		return column + ` LIKE ? ESCAPE '!'`, []interface{}{"%" + escapeLike(c.values[0].(string)) + "%"}
+	case "search":
+		// Every word of the search text must appear somewhere in the column
+		words := strings.Fields(c.values[0].(string))
+		if len(words) == 0 {
+			return "1=1", nil
+		}
+		conditions := make([]string, len(words))
+		args := make([]interface{}, len(words))
+		for i, word := range words {
+			conditions[i] = "LOWER(" + column + `) LIKE ? ESCAPE '!'`
+			args[i] = "%" + escapeLike(strings.ToLower(word)) + "%"
+		}
+		return "(" + strings.Join(conditions, " AND ") + ")", args
+	default:
+		return column + " " + sqlOperators[c.op] + " ?", values
+	}
+}
+
+func (c *comparison) Match(exoplanet models.Exoplanet) bool {
+	actual := fields[c.field].value(exoplanet)
+
+	switch c.op {
+	case "in":
+		for _, value := range c.values {
+			if compareValues(actual, value) == 0 {
+				return true
+			}
+		}
+		return false
+	case "prefix":
+		return strings.HasPrefix(strings.ToLower(actual.(string)), strings.ToLower(c.values[0].(string)))
+	case "contains":
+		return strings.Contains(strings.ToLower(actual.(string)), strings.ToLower(c.values[0].(string)))
+	case "search":
+		text := strings.ToLower(actual.(string))
+		for _, word := range strings.Fields(strings.ToLower(c.values[0].(string))) {
+			if !strings.Contains(text, word) {
+				return false
+			}
+		}
+		return true
+	}
+
+	order := compareValues(actual, c.values[0])
+	switch c.op {
+	case "eq":
+		return order == 0
+	case "ne":
+		return order != 0
+	case "gt":
+		return order > 0
+	case "ge":
+		return order >= 0
+	case "lt":
+		return order < 0
+	case "le":
+		return order <= 0
+	}
+	return false
+}
+
+// logical joins expressions with AND or OR
+type logical struct {
+	op    string
+	exprs []Expr
+}
+
+// And combines expressions so that all must match. Nil expressions are
+// skipped, and nil is returned when nothing remains.
+func And(exprs ...Expr) Expr {
+	return join("AND", exprs)
+}
+
+// Or combines expressions so that at least one must match
+func Or(exprs ...Expr) Expr {
+	return join("OR", exprs)
+}
+
+func join(op string, exprs []Expr) Expr {
+	var kept []Expr
+	for _, expr := range exprs {
+		if expr != nil {
+			kept = append(kept, expr)
+		}
+	}
+	switch len(kept) {
+	case 0:
+		return nil
+	case 1:
+		return kept[0]
+	}
+	return &logical{op: op, exprs: kept}
+}
+
+func (l *logical) SQL() (string, []interface{}) {
+	conditions := make([]string, len(l.exprs))
+	var args []interface{}
+	for i, expr := range l.exprs {
+		condition, exprArgs := expr.SQL()
+		conditions[i] = condition
+		args = append(args, exprArgs...)
+	}
+	return "(" + strings.Join(conditions, " "+l.op+" ") + ")", args
+}
+
+func (l *logical) Match(exoplanet models.Exoplanet) bool {
+	for _, expr := range l.exprs {
+		matched := expr.Match(exoplanet)
+		if l.op == "AND" && !matched {
+			return false
+		}
+		if l.op == "OR" && matched {
+			return true
+		}
+	}
+	return l.op == "AND"
+}
+
+// compareValues orders two values of the same kind. Strings are compared
+// ignoring case, like the LOWER() comparisons in SQL.
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return cmp.Compare(strings.ToLower(a), strings.ToLower(b.(string)))
+	}
+	return 0
+}
+
+// escapeLike escapes the LIKE wildcards using '!' as the escape character,
+// which behaves the same in MySQL and SQLite
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
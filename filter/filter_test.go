@@ -0,0 +1,119 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	kepler  = models.Exoplanet{ID: 1, Name: "Kepler-22b", Description: "An Earth-like exoplanet", Distance: 600, Radius: 2.4, Mass: 5.9, Type: models.Terrestrial, Gravity: 1.02}
+	jupiter = models.Exoplanet{ID: 2, Name: "Jupiter-like", Description: "A large gas giant", Distance: 1200, Radius: 11.2, Type: models.GasGiant, Gravity: 0.004}
+)
+
+// TestParse checks the generated SQL and the in-memory predicate agree
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		sql     string
+		args    []interface{}
+		kepler  bool
+		jupiter bool
+	}{
+		{"type eq 'Terrestrial'", "LOWER(type) = ?", []interface{}{"terrestrial"}, true, false},
+		{"name eq 'KEPLER-22B'", "LOWER(name) = ?", []interface{}{"kepler-22b"}, true, false},
+		{"name ne 'kepler-22b'", "LOWER(name) <> ?", []interface{}{"kepler-22b"}, false, true},
+		{"distance ge 600 and distance lt 1200", "(distance >= ? AND distance < ?)", []interface{}{600.0, 1200.0}, true, false},
+		{"radius gt 10 earth_radius or (mass ge 5 earth_mass and name prefix 'kep')", "(radius > ? OR (mass >= ? AND name LIKE ? ESCAPE '!'))", []interface{}{10.0, 5.0, "kep%"}, true, true},
+		{"type IN ('gasgiant', 'Ice')", "LOWER(type) IN (?, ?)", []interface{}{"gasgiant", "ice"}, false, true},
+		{"name contains '50%'", "name LIKE ? ESCAPE '!'", []interface{}{"%50!%%"}, false, false},
+		{"description search 'GAS large'", "(LOWER(description) LIKE ? ESCAPE '!' AND LOWER(description) LIKE ? ESCAPE '!')", []interface{}{"%gas%", "%large%"}, false, true},
+		{"gravity gt 1", "gravity > ?", []interface{}{1.0}, true, false},
+		{"name eq 'O''Brien'", "LOWER(name) = ?", []interface{}{"o'brien"}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			require.NoError(t, err)
+
+			sql, args := expr.SQL()
+			assert.Equal(t, tt.sql, sql)
+			assert.Equal(t, tt.args, args)
+			assert.Equal(t, tt.kepler, expr.Match(kepler))
+			assert.Equal(t, tt.jupiter, expr.Match(jupiter))
+		})
+	}
+}
+
+// TestParseEmpty checks that an empty filter matches everything
+func TestParseEmpty(t *testing.T) {
+	expr, err := Parse("  ")
+	require.NoError(t, err)
+	assert.Nil(t, expr)
+}
+
+// TestParseUnits checks that numbers are converted to storage units from
+// their unit, or from the unit system when they have none
+func TestParseUnits(t *testing.T) {
+	tests := []struct {
+		input   string
+		system  models.UnitSystem
+		value   float64
+		kepler  bool
+		jupiter bool
+	}{
+		{"radius gt 1 jupiter_radius", models.Astro, 10.9733, false, true},
+		{"distance lt 200 pc", models.Astro, 652.31, true, false},
+		{"distance lt 700", models.Astro, 700, true, false},
+		{"radius gt 1e7", models.SI, 1.5696, true, true},
+		{"mass ge 3e25", models.SI, 5.0233, true, false},
+		{"mass ge 1 jupiter_mass", models.SI, 317.83, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := ParseIn(tt.input, tt.system)
+			require.NoError(t, err)
+
+			_, args := expr.SQL()
+			require.Len(t, args, 1)
+			assert.InDelta(t, tt.value, args[0], 0.01)
+			assert.Equal(t, tt.kepler, expr.Match(kepler))
+			assert.Equal(t, tt.jupiter, expr.Match(jupiter))
+		})
+	}
+}
+
+// TestParseErrors checks that errors name the offending clause
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		clause string
+	}{
+		{"radius gt 'big'", "radius gt 'big'"},
+		{"type eq 'GasGiant' and colour eq 'red'", "colour"},
+		{"mass between 1", "mass between 1"},
+		{"type gt 'A'", "type gt 'A'"},
+		{"distance gt 1e999", "distance gt 1e999"},
+		{"(radius gt 1 earth_radius", "(radius gt 1 earth_radius"},
+		{"radius gt 2", "radius gt 2"},
+		{"distance lt 2 kg", "kg"},
+		{"name eq 'open", "'open"},
+		{"type in ('A' 'B')", "type in ('A' 'B'"},
+		{"radius gt 1 radius", "radius"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			require.Error(t, err)
+
+			var filterErr *Error
+			require.ErrorAs(t, err, &filterErr)
+			assert.Equal(t, tt.clause, filterErr.Clause)
+		})
+	}
+}
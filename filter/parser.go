@@ -0,0 +1,317 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+)
+
+/*
+	Filter grammar (keywords are case-insensitive):
+
+	expr       := term { "or" term }
+	term       := factor { "and" factor }
+	factor     := "(" expr ")" | comparison
+	comparison := field op value
+	            | field "in" "(" value { "," value } ")"
+	op         := eq | ne | gt | ge | lt | le | prefix | contains | search
+	value      := number [unit] | 'quoted string'   ('' escapes a quote)
+
+	e.g. type eq 'Terrestrial' and (radius gt 1.5 earth_radius or name prefix 'Kepler')
+
+	Distance, radius and mass take any unit of their dimension (see package
+	units), and are compared in models.StorageUnits. Numbers without a unit
+	are in the unit system of the request; in Astro, radius and mass need a
+	unit since responses show gas giants in Jupiter units.
+*/
+
+// tokenKind classifies lexer tokens
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind  tokenKind
+	text  string // identifier, number or unquoted string
+	start int    // byte offsets into the input
+	end   int
+}
+
+// Parse compiles a filter expression whose numbers without a unit are in
+// Astro units. An empty input yields a nil Expr.
+func Parse(input string) (Expr, error) {
+	return ParseIn(input, models.Astro)
+}
+
+// ParseIn compiles a filter expression whose numbers without a unit are in
+// the given unit system
+func ParseIn(input string, system models.UnitSystem) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	if tokens[0].kind == tokEOF {
+		return nil, nil
+	}
+
+	p := &parser{input: input, tokens: tokens, system: system}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorAt(tok.start, len(input), "unexpected trailing input")
+	}
+	return expr, nil
+}
+
+// lex splits the input into tokens
+func lex(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", start: i, end: i + 1})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", start: i, end: i + 1})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", start: i, end: i + 1})
+			i++
+		case c == '\'':
+			start := i
+			var value strings.Builder
+			i++
+			for {
+				if i >= len(input) {
+					return nil, &Error{Clause: input[start:], Reason: "unterminated string"}
+				}
+				if input[i] == '\'' {
+					if i+1 < len(input) && input[i+1] == '\'' {
+						value.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				value.WriteByte(input[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokString, text: value.String(), start: start, end: i})
+		case c == '-' || c == '+' || c == '.' || isDigit(c):
+			start := i
+			i++
+			for i < len(input) && (isDigit(input[i]) || strings.IndexByte(".eE+-", input[i]) >= 0) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: input[start:i], start: start, end: i})
+		case isLetter(c):
+			start := i
+			for i < len(input) && (isLetter(input[i]) || isDigit(input[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: input[start:i], start: start, end: i})
+		default:
+			return nil, &Error{Clause: input[i:], Reason: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, start: len(input), end: len(input)}), nil
+}
+
+// parser is a recursive-descent parser over the token stream
+type parser struct {
+	input  string
+	tokens []token
+	pos    int
+	system models.UnitSystem
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isKeyword reports whether tok is the given keyword
+func isKeyword(tok token, keyword string) bool {
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []Expr{left}
+	for isKeyword(p.peek(), "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, right)
+	}
+	return Or(exprs...), nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []Expr{left}
+	for isKeyword(p.peek(), "and") {
+		p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, right)
+	}
+	return And(exprs...), nil
+}
+
+func (p *parser) parseFactor() (Expr, error) {
+	tok := p.peek()
+	if tok.kind == tokLParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, p.errorAt(tok.start, closing.end, "missing closing parenthesis")
+		}
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	fieldTok := p.next()
+	if fieldTok.kind != tokIdent {
+		return nil, p.errorAt(fieldTok.start, fieldTok.end, "expected a field name")
+	}
+	fieldName := strings.ToLower(fieldTok.text)
+	if _, ok := fields[fieldName]; !ok {
+		return nil, p.errorAt(fieldTok.start, fieldTok.end, fmt.Sprintf("unknown field %q", fieldTok.text))
+	}
+
+	opTok := p.next()
+	if opTok.kind != tokIdent {
+		return nil, p.errorAt(fieldTok.start, opTok.end, "expected an operator")
+	}
+	op := strings.ToLower(opTok.text)
+
+	var values []interface{}
+	if op == "in" {
+		if open := p.next(); open.kind != tokLParen {
+			return nil, p.errorAt(fieldTok.start, open.end, "expected ( after in")
+		}
+		for {
+			value, err := p.parseValue(fieldName, fieldTok.start)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+
+			sep := p.next()
+			if sep.kind == tokRParen {
+				break
+			}
+			if sep.kind != tokComma {
+				return nil, p.errorAt(fieldTok.start, sep.end, "expected , or ) in list")
+			}
+		}
+	} else {
+		value, err := p.parseValue(fieldName, fieldTok.start)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	expr, err := Compare(fieldName, op, values...)
+	if err != nil {
+		// Report the clause as written rather than as reconstructed by Compare
+		err.(*Error).Clause = p.input[fieldTok.start:p.tokens[p.pos-1].end]
+		return nil, err
+	}
+	return expr, nil
+}
+
+// parseValue reads a number, with its unit for a quantity, or a string
+// literal for fieldName; clauseStart is used for errors
+func (p *parser) parseValue(fieldName string, clauseStart int) (interface{}, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		return tok.text, nil
+	case tokNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorAt(clauseStart, tok.end, fmt.Sprintf("%q is not a number", tok.text))
+		}
+
+		q := fields[fieldName].quantity
+		if q == nil {
+			return value, nil
+		}
+		if unit := p.peek(); unit.kind == tokIdent && !isKeyword(unit, "and") && !isKeyword(unit, "or") {
+			p.next()
+			value, err = q.convert(value, unit.text, q.unit(models.StorageUnits))
+			if err != nil {
+				return nil, p.errorAt(unit.start, unit.end, err.Error())
+			}
+			return value, nil
+		}
+		value, err = Quantity(fieldName, value, p.system)
+		if err != nil {
+			return nil, p.errorAt(clauseStart, tok.end, err.Error())
+		}
+		return value, nil
+	}
+	return nil, p.errorAt(clauseStart, tok.end, "expected a number or quoted string")
+}
+
+// errorAt builds an Error whose clause is input[start:end]
+func (p *parser) errorAt(start, end int, reason string) error {
+	clause := strings.TrimSpace(p.input[start:end])
+	if clause == "" {
+		clause = strings.TrimSpace(p.input[start:])
+	}
+	if clause == "" {
+		clause = p.input
+	}
+	return &Error{Clause: clause, Reason: reason}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/problem"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+	"github.com/gorilla/mux"
+)
+
+// apiKeyRepo is the storage backend used by the API key handlers
+var apiKeyRepo repository.APIKeyRepository
+
+// SetAPIKeyRepository allows the main function to set the API key storage backend
+func SetAPIKeyRepository(repo repository.APIKeyRepository) {
+	apiKeyRepo = repo
+}
+
+// issuedAPIKey is the response to IssueAPIKey, the only time the secret is shown
+type issuedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// IssueAPIKey handles creating a new API key
+func IssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name string      `json:"name"`
+		Role models.Role `json:"role"`
+	}
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	key, secret, err := models.NewAPIKey(request.Name, request.Role)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if err := key.Validate(); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if err := apiKeyRepo.Create(r.Context(), &key); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(issuedAPIKey{APIKey: key, Key: secret})
+}
+
+// ListAPIKeys handles listing issued API keys, without their secrets
+func ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := apiKeyRepo.List(r.Context())
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey handles revoking an API key by its ID
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, _ := strconv.Atoi(params["id"])
+
+	if err := apiKeyRepo.Revoke(r.Context(), id); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
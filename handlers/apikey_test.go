@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/anilsaini81155/spacevoyagers/middleware"
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAPIKeyLifecycle issues a key, authenticates with it and revokes it.
+func TestAPIKeyLifecycle(t *testing.T) {
+
+	loadEnvForTests()
+
+	// Issue
+	req := httptest.NewRequest("POST", "/admin/keys", bytes.NewBufferString(`{"name": "ci", "role": "editor"}`))
+	rr := httptest.NewRecorder()
+	IssueAPIKey(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var issued struct {
+		ID     int    `json:"id"`
+		Key    string `json:"key"`
+		Prefix string `json:"prefix"`
+		Hash   string `json:"hash"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &issued))
+	assert.Contains(t, issued.Key, models.APIKeyPrefix)
+	assert.Equal(t, issued.Key[:len(issued.Prefix)], issued.Prefix)
+	assert.Empty(t, issued.Hash)
+
+	req = httptest.NewRequest("POST", "/admin/keys", bytes.NewBufferString(`{"name": "ci", "role": "superuser"}`))
+	rr = httptest.NewRecorder()
+	IssueAPIKey(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// The key authenticates as an editor, which may write but not administer
+	protected := func(role models.Role) http.Handler {
+		return middleware.Authenticate(apiKeyRepo, nil, "")(middleware.Require(role, "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})))
+	}
+	call := func(role models.Role, key string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rr := httptest.NewRecorder()
+		protected(role).ServeHTTP(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusOK, call(models.RoleEditor, issued.Key))
+	assert.Equal(t, http.StatusForbidden, call(models.RoleAdmin, issued.Key))
+	assert.Equal(t, http.StatusUnauthorized, call(models.RoleViewer, ""))
+	assert.Equal(t, http.StatusUnauthorized, call(models.RoleViewer, issued.Key+"x"))
+
+	// List never shows secrets
+	rr = httptest.NewRecorder()
+	ListAPIKeys(rr, httptest.NewRequest("GET", "/admin/keys", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), issued.Key)
+	assert.Contains(t, rr.Body.String(), issued.Prefix)
+
+	// Revoke
+	revoke := func(id string) int {
+		req := mux.SetURLVars(httptest.NewRequest("DELETE", "/admin/keys/"+id, nil), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		RevokeAPIKey(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusNoContent, revoke(strconv.Itoa(issued.ID)))
+	assert.Equal(t, http.StatusUnauthorized, call(models.RoleViewer, issued.Key))
+	assert.Equal(t, http.StatusNoContent, revoke(strconv.Itoa(issued.ID)))
+	assert.Equal(t, http.StatusNotFound, revoke("999999"))
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/problem"
+)
+
+// decodeJSON decodes the request body into v. On failure it responds with a
+// 400 problem and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return checkJSON(w, r, json.NewDecoder(r.Body).Decode(v))
+}
+
+// decodeExoplanet decodes the request body into an exoplanet whose bare
+// numbers are in system. On failure it responds like decodeJSON.
+func decodeExoplanet(w http.ResponseWriter, r *http.Request, system models.UnitSystem) (models.Exoplanet, bool) {
+	var exoplanet models.Exoplanet
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "reading the request body: "+err.Error())
+		return exoplanet, false
+	}
+	return exoplanet, checkJSON(w, r, exoplanet.UnmarshalIn(body, system))
+}
+
+// checkJSON responds to an error from decoding JSON with a 400 problem,
+// listing the field when a value failed validation while decoding, and
+// returns whether err was nil
+func checkJSON(w http.ResponseWriter, r *http.Request, err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, models.ErrValidation) {
+		problem.Error(w, r, err)
+	} else {
+		problem.Write(w, r, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+	}
+	return false
+}
+
+// NotFound answers requests that match no route
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, r, http.StatusNotFound, "no route matches "+r.URL.Path)
+}
+
+// MethodNotAllowed answers requests whose route does not accept their method
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, r, http.StatusMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+}
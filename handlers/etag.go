@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/problem"
+)
+
+// etag returns the entity tag of an exoplanet version in a unit system, e.g.
+// "3-si", as each system is a different representation of the version
+func etag(version int, system models.UnitSystem) string {
+	return `"` + strconv.Itoa(version) + "-" + string(system) + `"`
+}
+
+// etagVersion returns the version an entity tag was issued for, whatever its
+// unit system
+func etagVersion(tag string) (int, bool) {
+	tag, ok := strings.CutPrefix(tag, `"`)
+	if !ok {
+		return 0, false
+	}
+	tag, _, ok = strings.Cut(strings.TrimSuffix(tag, `"`), "-")
+	if !ok {
+		return 0, false
+	}
+	version, err := strconv.Atoi(tag)
+	return version, err == nil
+}
+
+// matchesETag reports whether an If-Match or If-None-Match header lists tag
+// or is "*". Weak tags (W/"...") only match when weak is true, as If-Match
+// requires a strong comparison (RFC 9110).
+func matchesETag(header, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch responds with a 412 and returns false when the request has an
+// If-Match header that does not list the current version. A tag matches in
+// any unit system, so that a copy read in SI can be written back in Astro
+// units; weak tags never match.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, version int) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if tagged, ok := etagVersion(candidate); candidate == "*" || (ok && tagged == version) {
+			return true
+		}
+	}
+
+	system, err := models.ParseUnitSystem(r.URL.Query().Get("units"))
+	if err != nil {
+		system = models.Astro
+	}
+	problem.Write(w, r, http.StatusPreconditionFailed, "the exoplanet has changed since it was read; its current ETag is "+etag(version, system))
+	return false
+}
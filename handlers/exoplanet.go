@@ -2,52 +2,105 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/anilsaini81155/spacevoyagers/factory"
+	"github.com/anilsaini81155/spacevoyagers/filter"
+	"github.com/anilsaini81155/spacevoyagers/mission"
 	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/problem"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // var exoplanets []models.Exoplanet
 
 // var idCounter = 1
 
+// exoplanetRepo is the storage backend used by the exoplanet handlers
+var exoplanetRepo repository.ExoplanetRepository
+
+// tracer records handler spans below the request span
+var tracer = otel.Tracer("github.com/anilsaini81155/spacevoyagers/handlers")
+
+// SetRepository allows the main function to set the storage backend
+func SetRepository(repo repository.ExoplanetRepository) {
+	exoplanetRepo = repo
+}
+
+// unitSystem reads the units query parameter that selects how exoplanets
+// are represented in responses
+func unitSystem(w http.ResponseWriter, r *http.Request) (models.UnitSystem, bool) {
+	system, err := models.ParseUnitSystem(r.URL.Query().Get("units"))
+	if err != nil {
+		problem.Error(w, r, err)
+		return "", false
+	}
+	return system, true
+}
+
 // CreateExoplanet handles adding a new exoplanet
 func CreateExoplanet(w http.ResponseWriter, r *http.Request) {
-	var exoplanet models.Exoplanet
-	if err := json.NewDecoder(r.Body).Decode(&exoplanet); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	system, ok := unitSystem(w, r)
+	if !ok {
+		return
+	}
+
+	exoplanet, ok := decodeExoplanet(w, r, system)
+	if !ok {
 		return
 	}
 	// exoplanet.ID = idCounter
 	// idCounter++
 
 	if err := exoplanet.Validate(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Error(w, r, err)
 		return
 	}
 
 	exoplanetData, err := factory.CreateExoplanet(string(exoplanet.Type), exoplanet.Name, exoplanet.Description, exoplanet.Distance, exoplanet.Radius, exoplanet.Mass)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Error(w, r, err)
 		return
 	}
 
-	if err := models.AddExoplanet(&exoplanetData); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := exoplanetRepo.Create(r.Context(), &exoplanetData); err != nil {
+		problem.Error(w, r, err)
 		return
 	}
 
 	// exoplanets = append(exoplanets, exoplanet)
 
+	w.Header().Set("ETag", etag(exoplanetData.Version, system))
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(exoplanet)
+	json.NewEncoder(w).Encode(exoplanetData.View(system))
+}
+
+// Page size bounds for ListExoplanets
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// exoplanetPage is the response envelope returned by ListExoplanets
+type exoplanetPage struct {
+	Items      []models.View `json:"items"`
+	NextCursor *string       `json:"next_cursor"`
+	Total      *int          `json:"total,omitempty"`
 }
 
-// ListExoplanets handles listing all exoplanets
+// ListExoplanets handles listing exoplanets one page at a time
 /*
 	//sample input query params
 	GET /exoplanets?sort=distance
@@ -56,99 +109,179 @@ func CreateExoplanet(w http.ResponseWriter, r *http.Request) {
 
 	GET /exoplanets?min_distance=1000&max_distance=5000
 	GET /exoplanets?type=Terrestrial&sort=distance
+	GET /exoplanets?sort=type,-distance
+	GET /exoplanets?sort=-gravity,fuel
+
+	GET /exoplanets?filter=type eq 'Terrestrial' and (radius gt 1.5 earth_radius or name prefix 'Kepler')
+	GET /exoplanets?filter=mass in (1 earth_mass, 2.5 earth_mass) and description search 'earth like'
+	GET /exoplanets?filter=radius gt 1e8&units=si
+
+	GET /exoplanets?limit=20&include_total=true
+	GET /exoplanets?limit=20&cursor=<next_cursor from the previous page>
+	GET /exoplanets?units=si
 */
 
 func ListExoplanets(w http.ResponseWriter, r *http.Request) {
-	/*
-		//below code to get for all listing
-		exoplanets, err := models.GetAllExoplanets()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		json.NewEncoder(w).Encode(exoplanets)
-	*/
+	system, ok := unitSystem(w, r)
+	if !ok {
+		return
+	}
 
 	// Parse query parameters for filtering and sorting
-	sortField := r.URL.Query().Get("sort")
-	filterType := r.URL.Query().Get("type")
-	filterMinDistance := r.URL.Query().Get("min_distance")
-	filterMaxDistance := r.URL.Query().Get("max_distance")
-
-	// Construct SQL query
-	query := "SELECT id, name, description, distance, radius, mass, type FROM exoplanets WHERE 1=1"
-	args := []interface{}{}
-
-	// Apply filters based on the query parameters
-	if filterType != "" {
-		query += " AND type = ?"
-		args = append(args, filterType)
-	}
-
-	if filterMinDistance != "" {
-		minDistance, err := strconv.ParseFloat(filterMinDistance, 64)
-		if err == nil {
-			query += " AND distance >= ?"
-			args = append(args, minDistance)
-		}
+	opts := repository.ListOptions{Limit: defaultPageLimit}
+
+	expr, err := listFilter(r, system)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, err.Error())
+		return
 	}
+	opts.Filter = expr
 
-	if filterMaxDistance != "" {
-		maxDistance, err := strconv.ParseFloat(filterMaxDistance, 64)
-		if err == nil {
-			query += " AND distance <= ?"
-			args = append(args, maxDistance)
+	sortOrder, err := repository.ParseSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.Sort = sortOrder
+
+	// Parse pagination parameters
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("limit must be an integer between 1 and %d", maxPageLimit))
+			return
 		}
+		opts.Limit = limit
 	}
 
-	// Apply sorting based on the query parameters
-	if sortField != "" {
-		switch sortField {
-		case "name":
-			query += " ORDER BY name"
-		case "distance":
-			query += " ORDER BY distance"
-		case "radius":
-			query += " ORDER BY radius"
-		case "type":
-			query += " ORDER BY type"
-		default:
-			query += " ORDER BY id"
+	if cursorParam := r.URL.Query().Get("cursor"); cursorParam != "" {
+		cursor, err := repository.DecodeCursor(cursorParam, opts.Sort)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
 		}
-	} else {
-		query += " ORDER BY id"
+		opts.After = cursor
 	}
 
-	// Execute the query
-	rows, err := models.DB.Query(query, args...)
+	// Fetch one extra row to learn whether another page follows
+	pageLimit := opts.Limit
+	opts.Limit++
+
+	ctx, span := tracer.Start(r.Context(), "ListExoplanets", trace.WithAttributes(
+		attribute.String("exoplanets.sort", opts.Sort.String()),
+		attribute.Int("exoplanets.limit", pageLimit),
+		attribute.Bool("exoplanets.filtered", opts.Filter != nil),
+		attribute.Bool("exoplanets.cursor", opts.After != nil),
+	))
+	exoplanets, err := exoplanetRepo.List(ctx, opts)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.SetAttributes(attribute.Int("exoplanets.rows", len(exoplanets)))
+	span.End()
 	if err != nil {
-		log.Printf("Error querying exoplanets: %v", err)
-		http.Error(w, "Error retrieving exoplanets", http.StatusInternalServerError)
+		problem.Error(w, r, fmt.Errorf("querying exoplanets: %w", err))
 		return
 	}
-	defer rows.Close()
 
-	// Parse the results into a slice of exoplanets
-	var exoplanets []models.Exoplanet
-	for rows.Next() {
-		var exoplanet models.Exoplanet
-		err := rows.Scan(&exoplanet.ID, &exoplanet.Name, &exoplanet.Description, &exoplanet.Distance, &exoplanet.Radius, &exoplanet.Mass, &exoplanet.Type)
+	page := exoplanetPage{Items: []models.View{}}
+	if len(exoplanets) > pageLimit {
+		exoplanets = exoplanets[:pageLimit]
+		next := repository.NewCursor(opts.Sort, exoplanets[pageLimit-1]).Encode()
+		page.NextCursor = &next
+	}
+	for _, exoplanet := range exoplanets {
+		page.Items = append(page.Items, exoplanet.View(system))
+	}
+
+	if includeTotal, _ := strconv.ParseBool(r.URL.Query().Get("include_total")); includeTotal {
+		total, err := exoplanetRepo.Count(r.Context(), opts)
 		if err != nil {
-			log.Printf("Error scanning exoplanet: %v", err)
-			http.Error(w, "Error retrieving exoplanets", http.StatusInternalServerError)
+			problem.Error(w, r, fmt.Errorf("counting exoplanets: %w", err))
 			return
 		}
-		exoplanets = append(exoplanets, exoplanet)
+		page.Total = &total
+	}
+
+	// Advertise the neighbouring pages (RFC 8288)
+	var links []string
+	if page.NextCursor != nil {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(r, *page.NextCursor)))
+	}
+	if opts.After != nil {
+		links = append(links, fmt.Sprintf(`<%s>; rel="first"`, pageURL(r, "")))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
 	}
 
 	// Send the response back as JSON
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(exoplanets)
+	json.NewEncoder(w).Encode(page)
+}
+
+// listFilter combines the filter query parameter with the legacy type,
+// min_distance and max_distance parameters. Numbers without a unit are in the
+// requested unit system.
+func listFilter(r *http.Request, system models.UnitSystem) (filter.Expr, error) {
+	expr, err := filter.ParseIn(r.URL.Query().Get("filter"), system)
+	if err != nil {
+		return nil, err
+	}
+	exprs := []filter.Expr{expr}
+
+	if filterType := r.URL.Query().Get("type"); filterType != "" {
+		typeExpr, err := filter.Compare("type", "eq", filterType)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, typeExpr)
+	}
+
+	for _, bound := range []struct{ param, op string }{{"min_distance", "ge"}, {"max_distance", "le"}} {
+		raw := r.URL.Query().Get(bound.param)
+		if raw == "" {
+			continue
+		}
+		distance, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", bound.param)
+		}
+		if distance, err = filter.Quantity("distance", distance, system); err != nil {
+			return nil, err
+		}
+		distanceExpr, err := filter.Compare("distance", bound.op, distance)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, distanceExpr)
+	}
+
+	return filter.And(exprs...), nil
+}
+
+// pageURL returns the request URL with its cursor replaced, or removed when
+// cursor is empty
+func pageURL(r *http.Request, cursor string) string {
+	query := r.URL.Query()
+	if cursor == "" {
+		query.Del("cursor")
+	} else {
+		query.Set("cursor", cursor)
+	}
+
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
 }
 
 // GetExoplanetByID handles fetching an exoplanet by its ID
 func GetExoplanetByID(w http.ResponseWriter, r *http.Request) {
+	system, ok := unitSystem(w, r)
+	if !ok {
+		return
+	}
+
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 
@@ -162,17 +295,30 @@ func GetExoplanetByID(w http.ResponseWriter, r *http.Request) {
 
 		http.Error(w, "Exoplanet not found", http.StatusNotFound)
 	*/
-	exoplanet, err := models.GetExoplanetByID(id)
+	exoplanet, err := exoplanetRepo.GetByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		problem.Error(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(exoplanet)
+	// Conditional GET: the client's copy is current
+	tag := etag(exoplanet.Version, system)
+	w.Header().Set("ETag", tag)
+	if header := r.Header.Get("If-None-Match"); header != "" && matchesETag(header, tag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	json.NewEncoder(w).Encode(exoplanet.View(system))
 }
 
 // UpdateExoplanet handles updating an exoplanet by its ID
 func UpdateExoplanet(w http.ResponseWriter, r *http.Request) {
+	system, ok := unitSystem(w, r)
+	if !ok {
+		return
+	}
+
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 
@@ -200,25 +346,166 @@ func UpdateExoplanet(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Exoplanet not found", http.StatusNotFound)
 	*/
 
-	var updatedExoplanet models.Exoplanet
-	if err := json.NewDecoder(r.Body).Decode(&updatedExoplanet); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	updatedExoplanet, ok := decodeExoplanet(w, r, system)
+	if !ok {
 		return
 	}
 
 	if err := updatedExoplanet.Validate(); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Error(w, r, err)
 		return
 	}
 
 	updatedExoplanet.ID = id
 
-	if err := models.UpdateExoplanet(&updatedExoplanet); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// With If-Match, only replace the version the client has seen
+	if r.Header.Get("If-Match") != "" {
+		current, err := exoplanetRepo.GetByID(r.Context(), id)
+		if err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+		updatedExoplanet.Version = current.Version
+	}
+
+	if err := exoplanetRepo.Update(r.Context(), &updatedExoplanet); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(updatedExoplanet.Version, system))
+	json.NewEncoder(w).Encode(updatedExoplanet.View(system))
+}
+
+// Media types of the patch documents accepted by PatchExoplanet
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// PatchExoplanet handles partially updating an exoplanet by its ID with a
+// JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902). The patch applies to
+// the exoplanet's models.Document in the requested units, and the result is
+// validated as a whole.
+/*
+	//sample requests
+	PATCH /exoplanets/1
+	Content-Type: application/merge-patch+json
+	{"description": "Tidally locked", "radius": 1.3}
+
+	PATCH /exoplanets/1
+	Content-Type: application/json-patch+json
+	[{"op": "test", "path": "/name", "value": "Proxima b"}, {"op": "replace", "path": "/mass/value", "value": 1.1}]
+*/
+func PatchExoplanet(w http.ResponseWriter, r *http.Request) {
+	system, ok := unitSystem(w, r)
+	if !ok {
+		return
+	}
+
+	params := mux.Vars(r)
+	id, _ := strconv.Atoi(params["id"])
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mergePatchType && mediaType != jsonPatchType {
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		problem.Write(w, r, http.StatusUnsupportedMediaType, "the patch must be sent as "+mergePatchType+" or "+jsonPatchType)
+		return
+	}
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "reading the patch: "+err.Error())
+		return
+	}
+
+	// The patch only writes over the version it was applied to. With
+	// If-Match a newer version fails the precondition; without it, the client
+	// asked for no precondition, so the patch is applied again to the new
+	// version.
+	conditional := r.Header.Get("If-Match") != ""
+	for attempt := 1; ; attempt++ {
+		exoplanet, err := exoplanetRepo.GetByID(r.Context(), id)
+		if err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		if !checkIfMatch(w, r, exoplanet.Version) {
+			return
+		}
+
+		patched, ok := applyPatch(w, r, mediaType, exoplanet.Document(system), patch)
+		if !ok {
+			return
+		}
+		var updatedExoplanet models.Exoplanet
+		if !checkJSON(w, r, updatedExoplanet.UnmarshalIn(patched, system)) {
+			return
+		}
+		if err := updatedExoplanet.Validate(); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+
+		updatedExoplanet.ID = id
+		updatedExoplanet.Version = exoplanet.Version
+		err = exoplanetRepo.Update(r.Context(), &updatedExoplanet)
+		if errors.Is(err, models.ErrStale) && !conditional {
+			if attempt < patchAttempts {
+				continue
+			}
+			problem.Write(w, r, http.StatusConflict, "the exoplanet kept changing while the patch was applied; retry the request")
+			return
+		}
+		if err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+
+		w.Header().Set("ETag", etag(updatedExoplanet.Version, system))
+		json.NewEncoder(w).Encode(updatedExoplanet.View(system))
 		return
 	}
+}
+
+// patchAttempts bounds how often PatchExoplanet reapplies a patch without
+// If-Match when concurrent writes keep moving the version
+const patchAttempts = 3
+
+// applyPatch applies a merge patch or a JSON patch to doc, responding with
+// the error and returning false when it cannot be applied
+func applyPatch(w http.ResponseWriter, r *http.Request, mediaType string, doc models.Document, patch []byte) ([]byte, bool) {
+	original, err := json.Marshal(doc)
+	if err != nil {
+		problem.Error(w, r, err)
+		return nil, false
+	}
+
+	if mediaType == mergePatchType {
+		patched, err := jsonpatch.MergePatch(original, patch)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid merge patch: "+err.Error())
+			return nil, false
+		}
+		return patched, true
+	}
 
-	json.NewEncoder(w).Encode(updatedExoplanet)
+	operations, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "invalid JSON patch: "+err.Error())
+		return nil, false
+	}
+	patched, err := operations.Apply(original)
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		problem.Write(w, r, http.StatusConflict, "the exoplanet does not match the patch: "+err.Error())
+		return nil, false
+	} else if err != nil {
+		problem.Write(w, r, http.StatusUnprocessableEntity, "the patch cannot be applied: "+err.Error())
+		return nil, false
+	}
+	return patched, true
 }
 
 // DeleteExoplanet handles removing an exoplanet by its ID
@@ -237,8 +524,22 @@ func DeleteExoplanet(w http.ResponseWriter, r *http.Request) {
 
 		http.Error(w, "Exoplanet not found", http.StatusNotFound)
 	*/
-	if err := models.DeleteExoplanet(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// With If-Match, only delete the version the client has seen
+	version := 0
+	if r.Header.Get("If-Match") != "" {
+		current, err := exoplanetRepo.GetByID(r.Context(), id)
+		if err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+		version = current.Version
+	}
+
+	if err := exoplanetRepo.Delete(r.Context(), id, version); err != nil {
+		problem.Error(w, r, err)
 		return
 	}
 
@@ -252,15 +553,33 @@ func FuelEstimation(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(params["id"])
 	crewCapacity, _ := strconv.Atoi(r.URL.Query().Get("crewCapacity"))
 
-	exoplanet, err := models.GetExoplanetByID(id)
+	exoplanet, err := exoplanetRepo.GetByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		problem.Error(w, r, err)
+		return
+	}
+
+	// With a catalogued ship, plan the full mission and enforce its limits
+	if shipParam := r.URL.Query().Get("ship_id"); shipParam != "" {
+		shipID, err := strconv.Atoi(shipParam)
+		if err != nil || shipID <= 0 {
+			problem.Error(w, r, models.InvalidField("ship_id", models.CodeOutOfRange, "must be a positive integer"))
+			return
+		}
+
+		plan, ok := planMission(w, r, exoplanet, mission.Request{ShipID: shipID, Crew: crewCapacity})
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"fuel": plan.TotalPropellant, "ship_id": shipID, "plan": plan})
 		return
 	}
 
 	fuel, err := exoplanet.FuelEstimation(crewCapacity)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Error(w, r, err)
 		return
 	}
 	json.NewEncoder(w).Encode(map[string]float64{"fuel": fuel})
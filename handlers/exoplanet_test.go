@@ -2,20 +2,30 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 
+	"github.com/anilsaini81155/spacevoyagers/config"
 	"github.com/anilsaini81155/spacevoyagers/db"
 	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/problem"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+	"github.com/anilsaini81155/spacevoyagers/units"
 	_ "github.com/go-sql-driver/mysql" // for MySQL driver
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func init() {
@@ -41,15 +51,26 @@ func TestMain(m *testing.M) {
 		log.Println("Warning: .envtest file not found, skipping...")
 	}
 
-	// Initialize the database connection
-	DB, dberr := db.GetDB()
-	if dberr != nil {
-		log.Fatalf("Error connecting to the database: %v", dberr)
+	// Initialize the storage backend selected by DB_DRIVER; .envtest defaults
+	// to the in-memory backend so no database server is needed
+	cfg, _, cfgErr := config.Load(nil)
+	if cfgErr != nil {
+		log.Fatalf("Error loading configuration: %v", cfgErr)
+	}
+	db.Configure(cfg.Database.Settings())
+	repos, repoErr := repository.Open(db.Driver())
+	if repoErr != nil {
+		log.Fatalf("Error initializing storage: %v", repoErr)
+	}
+	if db.Driver() != db.DriverMemory {
+		if err := models.RunMigrations(); err != nil {
+			log.Fatalf("Error applying migrations: %v", err)
+		}
 	}
 
-	models.SetDB(DB)
-	// Run migrations before tests
-	models.RunMigrations()
+	SetRepository(repos.Exoplanets)
+	SetShipRepository(repos.Ships)
+	SetAPIKeyRepository(repos.APIKeys)
 
 	// Run the tests
 	code := m.Run()
@@ -66,7 +87,7 @@ func TestCreateExoplanet(t *testing.T) {
         "name": "Planet X",
         "description": "A mysterious planet.",
         "distance": 4500,
-        "radius": 50,
+        "radius": 1.5,
         "mass": 5,
         "type": "Terrestrial"
     }`
@@ -100,13 +121,51 @@ func TestCreateExoplanet(t *testing.T) {
 	assert.Equal(t, "Planet X", response["name"])
 	assert.Equal(t, "A mysterious planet.", response["description"])
 	assert.Equal(t, 4500, int(response["distance"].(float64)))
-	assert.Equal(t, 50, int(response["radius"].(float64)))
+	assert.InDelta(t, 1.5, response["radius"], 1e-9)
 	assert.Equal(t, 5, int(response["mass"].(float64)))
 	assert.Equal(t, "Terrestrial", response["type"])
+	assert.InDelta(t, 5*models.GravitationalConstant*models.EarthMass/(1.5*1.5*models.EarthRadius*models.EarthRadius), response["gravity"], 1e-9)
+	assert.Equal(t, "earth_mass", response["units"].(map[string]interface{})["mass"])
+	assert.NotZero(t, response["physics"].(map[string]interface{})["escape_velocity"])
 
 	// assert.Equal(t, "Exoplanet added successfully", response["message"])
 }
 
+// TestCreateExoplanetWithUnits checks unit-tagged input and SI output
+func TestCreateExoplanetWithUnits(t *testing.T) {
+	loadEnvForTests()
+
+	requestBody := `{"name":"Far Giant","description":"In parsecs","distance":{"value":100,"unit":"pc"},"radius":{"value":2,"unit":"jupiter_radius"},"mass":3e27,"type":"GasGiant"}`
+	req := httptest.NewRequest("POST", "/exoplanets?units=si", bytes.NewBufferString(requestBody))
+	rr := httptest.NewRecorder()
+	CreateExoplanet(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var response models.View
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.InDelta(t, 100*units.Parsec, response.Distance, 1e6)
+	assert.InDelta(t, 2*units.JupiterRadius, response.Radius, 1)
+	assert.InDelta(t, 3e27, response.Mass, 1e15) // bare numbers are in the requested units
+	assert.Equal(t, models.Units{Distance: "m", Radius: "m", Mass: "kg", Gravity: "m/s2"}, response.Units)
+
+	// Stored canonically and read back in the default astro units
+	stored, err := exoplanetRepo.GetByID(context.Background(), response.ID)
+	require.NoError(t, err)
+	assert.InDelta(t, 2*units.JupiterRadius/units.EarthRadius, stored.Radius, 1e-9)
+
+	rr = httptest.NewRecorder()
+	GetExoplanetByID(rr, mux.SetURLVars(httptest.NewRequest("GET", "/exoplanets/"+strconv.Itoa(response.ID), nil), map[string]string{"id": strconv.Itoa(response.ID)}))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.InDelta(t, 326.156, response.Distance, 1e-3)
+	assert.InDelta(t, 2, response.Radius, 1e-9)
+	assert.Equal(t, "jupiter_radius", response.Units.Radius)
+
+	rr = httptest.NewRecorder()
+	ListExoplanets(rr, httptest.NewRequest("GET", "/exoplanets?units=imperial", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
 // TestListExoplanets tests the ListExoplanets handler.
 func TestListExoplanets(t *testing.T) {
 
@@ -126,15 +185,17 @@ func TestListExoplanets(t *testing.T) {
 	// Check the response code
 	assert.Equal(t, http.StatusOK, rr.Code)
 
-	// Check the response body (assuming the response should contain a list)
-	var response []map[string]interface{}
+	// Check the response body (assuming the response should contain a page of items)
+	var response struct {
+		Items []map[string]interface{} `json:"items"`
+	}
 	err = json.Unmarshal(rr.Body.Bytes(), &response)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Assuming at least one exoplanet exists
-	assert.GreaterOrEqual(t, len(response), 1)
+	assert.GreaterOrEqual(t, len(response.Items), 1)
 }
 
 // TestListExoplanetsWithSortingAndFiltering tests the ListExoplanets handler with sorting and filtering.
@@ -158,18 +219,173 @@ func TestListExoplanetsWithSortingAndFiltering(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rr.Code)
 
 	// Check the response body
-	var response []map[string]interface{}
+	var response struct {
+		Items []map[string]interface{} `json:"items"`
+	}
 	err = json.Unmarshal(rr.Body.Bytes(), &response)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Assuming the database has planets of type 'Terrestrial'
-	for _, planet := range response {
+	for _, planet := range response.Items {
 		assert.Equal(t, "Terrestrial", planet["type"])
 	}
 }
 
+// TestListExoplanetsInvalidQuery tests that bad filters and sorts are rejected with a 400 naming the problem.
+func TestListExoplanetsInvalidQuery(t *testing.T) {
+
+	loadEnvForTests()
+
+	for query, message := range map[string]string{
+		"filter=" + url.QueryEscape("type eq 'GasGiant' and radius gt 'big'"): `"radius gt 'big'"`,
+		"min_distance=far":                         "min_distance must be a number",
+		"filter=" + url.QueryEscape("radius gt 2"): "give a unit, e.g. radius gt 1.5 earth_radius",
+		"sort=type,-colour":                        `unknown sort field "-colour"`,
+	} {
+		req, err := http.NewRequest("GET", "/exoplanets?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(ListExoplanets).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+		var details problem.Details
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &details))
+		assert.Contains(t, details.Detail, message)
+	}
+}
+
+// TestListExoplanetsFilterUnits tests that filter values are read in their
+// unit, or in the requested unit system when they have none.
+func TestListExoplanetsFilterUnits(t *testing.T) {
+
+	loadEnvForTests()
+
+	giant := models.Exoplanet{Name: "Filtered Giant", Description: "Two Jupiter radii", Distance: 300, Radius: 2 * models.JupiterRadius / models.EarthRadius, Mass: 317.8, Type: models.GasGiant}
+	require.NoError(t, exoplanetRepo.Create(context.Background(), &giant))
+	defer exoplanetRepo.Delete(context.Background(), giant.ID, 0)
+
+	for query, found := range map[string]bool{
+		"filter=" + url.QueryEscape("name eq 'Filtered Giant' and radius lt 3 jupiter_radius"):  true,
+		"filter=" + url.QueryEscape("name eq 'Filtered Giant' and radius lt 3 earth_radius"):    false,
+		"filter=" + url.QueryEscape("name eq 'Filtered Giant' and radius gt 1e8") + "&units=si": true,
+		"filter=" + url.QueryEscape("name eq 'Filtered Giant'") + "&max_distance=100":           false,
+		"filter=" + url.QueryEscape("name eq 'Filtered Giant'") + "&max_distance=3e18&units=si": true,
+	} {
+		req := httptest.NewRequest("GET", "/exoplanets?"+query, nil)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(ListExoplanets).ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var response struct {
+			Items []models.View `json:"items"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, found, len(response.Items) == 1, query)
+	}
+}
+
+// TestCreateExoplanetInvalidFields tests that every invalid field is listed in the problem response.
+func TestCreateExoplanetInvalidFields(t *testing.T) {
+
+	loadEnvForTests()
+
+	body := []byte(`{"name":"","description":"Hot Jupiter","distance":-1,"radius":1.2,"mass":0,"type":"GasGiant"}`)
+	req, err := http.NewRequest("POST", "/exoplanets", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(CreateExoplanet).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	var details problem.Details
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &details))
+	assert.Equal(t, http.StatusBadRequest, details.Status)
+	assert.Equal(t, "/exoplanets", details.Instance)
+	assert.Equal(t, []models.FieldError{
+		{Field: "name", Code: models.CodeRequired, Message: "is required"},
+		{Field: "distance", Code: models.CodeOutOfRange, Message: "must be positive"},
+		{Field: "mass", Code: models.CodeRequired, Message: "required for gasgiant exoplanets"},
+	}, details.Errors)
+}
+
+// TestListExoplanetsPagination walks the listing page by page using next_cursor.
+func TestListExoplanetsPagination(t *testing.T) {
+
+	loadEnvForTests()
+
+	// Seed planets far enough away to be isolated by a distance filter
+	for _, distance := range []float64{900003, 900001, 900002} {
+		exoplanet := models.Exoplanet{Name: "Paged", Description: "Pagination", Distance: distance, Radius: 1, Mass: 1, Type: models.Terrestrial}
+		if err := exoplanetRepo.Create(context.Background(), &exoplanet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type page struct {
+		Items      []models.Exoplanet `json:"items"`
+		NextCursor *string            `json:"next_cursor"`
+		Total      *int               `json:"total"`
+	}
+
+	next := "/exoplanets?min_distance=900000&sort=distance&limit=2&include_total=true"
+	var distances []float64
+	for pages := 0; next != ""; pages++ {
+		if pages > 2 {
+			t.Fatal("pagination did not terminate")
+		}
+
+		req, err := http.NewRequest("GET", next, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(ListExoplanets).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var response page
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 3, *response.Total)
+
+		for _, exoplanet := range response.Items {
+			distances = append(distances, exoplanet.Distance)
+		}
+
+		next = ""
+		if response.NextCursor != nil {
+			assert.Contains(t, rr.Header().Get("Link"), `rel="next"`)
+			next = "/exoplanets?min_distance=900000&sort=distance&limit=2&include_total=true&cursor=" + *response.NextCursor
+		}
+	}
+
+	assert.Equal(t, []float64{900001, 900002, 900003}, distances)
+
+	// A cursor issued for one sort order is rejected for another
+	req, _ := http.NewRequest("GET", "/exoplanets?sort=distance&limit=1", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ListExoplanets).ServeHTTP(rr, req)
+	var first page
+	if err := json.Unmarshal(rr.Body.Bytes(), &first); err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, first.NextCursor) {
+		req, _ = http.NewRequest("GET", "/exoplanets?sort=name&cursor="+*first.NextCursor, nil)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(ListExoplanets).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+}
+
 // TestGetExoplanetByID tests the GetExoplanetByID handler.
 func TestGetExoplanetByID(t *testing.T) {
 
@@ -212,7 +428,7 @@ func TestUpdateExoplanet(t *testing.T) {
         "name": "Updated Planet",
         "description": "Updated description",
         "distance": 5000,
-        "radius": 100,
+        "radius": 2,
         "mass": 10,
         "type": "Terrestrial"
     }`
@@ -244,7 +460,7 @@ func TestUpdateExoplanet(t *testing.T) {
 	assert.Equal(t, "Updated Planet", response["name"])
 	assert.Equal(t, "Updated description", response["description"])
 	assert.Equal(t, 5000, int(response["distance"].(float64)))
-	assert.Equal(t, 100, int(response["radius"].(float64)))
+	assert.Equal(t, 2, int(response["radius"].(float64)))
 	assert.Equal(t, 10, int(response["mass"].(float64)))
 	assert.Equal(t, "Terrestrial", response["type"])
 
@@ -283,19 +499,244 @@ func TestDeleteExoplanet(t *testing.T) {
 	// assert.Equal(t, "Exoplanet deleted successfully", response["message"])
 }
 
+// TestPatchExoplanet tests merge patches, JSON patches and their failures.
+func TestPatchExoplanet(t *testing.T) {
+
+	loadEnvForTests()
+
+	exoplanet := models.Exoplanet{Name: "Patch Planet", Description: "Before", Distance: 100, Radius: 2, Mass: 4, Type: models.Terrestrial}
+	if err := exoplanetRepo.Create(context.Background(), &exoplanet); err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.Itoa(exoplanet.ID)
+
+	patch := func(contentType, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("PATCH", "/exoplanets/"+id, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(PatchExoplanet).ServeHTTP(rr, req)
+		return rr
+	}
+
+	// A merge patch changes only the fields it names
+	rr := patch("application/merge-patch+json", `{"description": "After", "radius": 1.5}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	got, err := exoplanetRepo.GetByID(context.Background(), exoplanet.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Patch Planet", got.Name)
+	assert.Equal(t, "After", got.Description)
+	assert.InDelta(t, 1.5, got.Radius, 1e-9)
+	assert.InDelta(t, 4, got.Mass, 1e-9)
+
+	// Quantities keep their unit when a JSON patch changes the type
+	rr = patch("application/json-patch+json", `[
+		{"op": "test", "path": "/name", "value": "Patch Planet"},
+		{"op": "replace", "path": "/type", "value": "GasGiant"},
+		{"op": "replace", "path": "/mass/value", "value": 300}
+	]`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	got, err = exoplanetRepo.GetByID(context.Background(), exoplanet.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.GasGiant, got.Type)
+	assert.InDelta(t, 1.5, got.Radius, 1e-9)
+	assert.InDelta(t, 300, got.Mass, 1e-9)
+
+	// The merged result is validated as a whole
+	rr = patch("application/merge-patch+json", `{"name": "", "mass": null}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	var details problem.Details
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &details))
+	assert.Equal(t, []models.FieldError{
+		{Field: "name", Code: models.CodeRequired, Message: "is required"},
+		{Field: "mass", Code: models.CodeRequired, Message: "required for gasgiant exoplanets"},
+	}, details.Errors)
+
+	for _, tt := range []struct {
+		contentType, body string
+		code              int
+	}{
+		{"application/json-patch+json", `[{"op": "test", "path": "/name", "value": "Other"}]`, http.StatusConflict},
+		{"application/json-patch+json", `[{"op": "remove", "path": "/colour"}]`, http.StatusUnprocessableEntity},
+		{"application/json-patch+json", `{"op": "remove"}`, http.StatusBadRequest},
+		{"application/merge-patch+json", `{"radius": "big"}`, http.StatusBadRequest},
+		{"application/json", `{"radius": 1}`, http.StatusUnsupportedMediaType},
+	} {
+		rr = patch(tt.contentType, tt.body)
+		assert.Equal(t, tt.code, rr.Code, tt.body)
+		assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"), tt.body)
+	}
+
+	// Failed patches change nothing
+	got, err = exoplanetRepo.GetByID(context.Background(), exoplanet.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Patch Planet", got.Name)
+}
+
+// TestPatchExoplanetInSI checks that bare numbers in a patch are read in the
+// requested units, so an SI document round-trips unchanged
+func TestPatchExoplanetInSI(t *testing.T) {
+	loadEnvForTests()
+
+	exoplanet := models.Exoplanet{Name: "SI Planet", Description: "Metric", Distance: 40, Radius: 1.2, Mass: 2, Type: models.Terrestrial}
+	require.NoError(t, exoplanetRepo.Create(context.Background(), &exoplanet))
+	id := strconv.Itoa(exoplanet.ID)
+	call := func(method string, handler http.HandlerFunc, body string) models.View {
+		req := httptest.NewRequest(method, "/exoplanets/"+id+"?units=si", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		rr := httptest.NewRecorder()
+		handler(rr, mux.SetURLVars(req, map[string]string{"id": id}))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var view models.View
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &view))
+		return view
+	}
+
+	before := call("GET", GetExoplanetByID, "")
+	patch, err := json.Marshal(map[string]float64{"distance": before.Distance, "radius": before.Radius, "mass": before.Mass})
+	require.NoError(t, err)
+	after := call("PATCH", PatchExoplanet, string(patch))
+	assert.InDelta(t, before.Distance, after.Distance, before.Distance*1e-12)
+	assert.InDelta(t, before.Radius, after.Radius, before.Radius*1e-12)
+	assert.InDelta(t, before.Mass, after.Mass, before.Mass*1e-12)
+
+	got, err := exoplanetRepo.GetByID(context.Background(), exoplanet.ID)
+	require.NoError(t, err)
+	assert.InDelta(t, 40, got.Distance, 1e-9)
+	assert.InDelta(t, 1.2, got.Radius, 1e-9)
+	assert.InDelta(t, 2, got.Mass, 1e-9)
+
+	// PUT reads bare numbers in the requested units too
+	after = call("PUT", UpdateExoplanet, fmt.Sprintf(`{"name":"SI Planet","description":"Metric","distance":%g,"radius":%g,"mass":%g,"type":"Terrestrial"}`, before.Distance, before.Radius, before.Mass))
+	assert.InDelta(t, before.Radius, after.Radius, before.Radius*1e-12)
+}
+
+// TestMissingExoplanet tests that PUT, PATCH and DELETE report a missing ID with a 404.
+func TestMissingExoplanet(t *testing.T) {
+
+	loadEnvForTests()
+
+	body := `{"name": "Ghost", "description": "Not there", "distance": 10, "radius": 1, "mass": 1, "type": "Terrestrial"}`
+	for _, tt := range []struct {
+		method, contentType string
+		handler             http.HandlerFunc
+	}{
+		{"PUT", "application/json", UpdateExoplanet},
+		{"PATCH", "application/merge-patch+json", PatchExoplanet},
+		{"DELETE", "", DeleteExoplanet},
+	} {
+		req, err := http.NewRequest(tt.method, "/exoplanets/999999", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", tt.contentType)
+		req = mux.SetURLVars(req, map[string]string{"id": "999999"})
+
+		rr := httptest.NewRecorder()
+		tt.handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code, tt.method)
+		assert.Contains(t, rr.Body.String(), "exoplanet not found", tt.method)
+	}
+}
+
+// TestExoplanetConditionalRequests tests ETags, If-None-Match and If-Match.
+func TestExoplanetConditionalRequests(t *testing.T) {
+
+	loadEnvForTests()
+
+	exoplanet := models.Exoplanet{Name: "Contested", Description: "Two editors", Distance: 100, Radius: 2, Mass: 4, Type: models.Terrestrial}
+	if err := exoplanetRepo.Create(context.Background(), &exoplanet); err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.Itoa(exoplanet.ID)
+
+	send := func(method string, handler http.HandlerFunc, headers map[string]string, body string) *httptest.ResponseRecorder {
+		target := "/exoplanets/" + id
+		if units, ok := headers["units"]; ok {
+			target += "?units=" + units
+			delete(headers, "units")
+		}
+		req, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	body := `{"name": "Contested", "description": "Edited", "distance": 100, "radius": 2, "mass": 4, "type": "Terrestrial"}`
+
+	rr := send("GET", GetExoplanetByID, nil, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"1-astro"`, rr.Header().Get("ETag"))
+
+	// The client's copy is current
+	rr = send("GET", GetExoplanetByID, map[string]string{"If-None-Match": `"0-astro", W/"1-astro"`}, "")
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+
+	// Each unit system is a different representation of the version
+	rr = send("GET", GetExoplanetByID, map[string]string{"units": "si", "If-None-Match": `"1-astro"`}, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"1-si"`, rr.Header().Get("ETag"))
+
+	// The first editor wins and gets the new ETag; a tag read in SI guards
+	// the version it was read at
+	rr = send("PUT", UpdateExoplanet, map[string]string{"If-Match": `"1-si"`}, body)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, `"2-astro"`, rr.Header().Get("ETag"))
+
+	// The second editor still holds version 1
+	rr = send("PUT", UpdateExoplanet, map[string]string{"If-Match": `"1-astro"`}, body)
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+	rr = send("PATCH", PatchExoplanet, map[string]string{"If-Match": `"1-astro"`, "Content-Type": "application/merge-patch+json"}, `{"description": "Mine"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+	rr = send("DELETE", DeleteExoplanet, map[string]string{"If-Match": `W/"2-astro"`}, "")
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+	rr = send("GET", GetExoplanetByID, map[string]string{"If-None-Match": `"1-astro"`}, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Edited")
+
+	rr = send("PATCH", PatchExoplanet, map[string]string{"If-Match": `"2-astro"`, "Content-Type": "application/merge-patch+json"}, `{"description": "Mine"}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, `"3-astro"`, rr.Header().Get("ETag"))
+
+	rr = send("DELETE", DeleteExoplanet, map[string]string{"If-Match": `"3-astro"`}, "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+}
+
 // TestFuelEstimation tests the FuelEstimation handler.
 func TestFuelEstimation(t *testing.T) {
 
 	loadEnvForTests()
 
+	// Seed an exoplanet, since earlier tests delete the first one
+	exoplanet := models.Exoplanet{Name: "Fuel Planet", Description: "Fuel target", Distance: 100, Radius: 2, Mass: 4, Type: models.Terrestrial}
+	if err := exoplanetRepo.Create(context.Background(), &exoplanet); err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.Itoa(exoplanet.ID)
+
 	// Mock request for fuel estimation
-	req, err := http.NewRequest("GET", "/exoplanets/1/fuel?crewCapacity=5", nil)
+	req, err := http.NewRequest("GET", "/exoplanets/"+id+"/fuel?crewCapacity=5", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// Add a path parameter to the request
-	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = mux.SetURLVars(req, map[string]string{"id": id})
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(FuelEstimation)
@@ -318,3 +759,128 @@ func TestFuelEstimation(t *testing.T) {
 	// Assuming fuel calculation logic is correct, assert response
 	assert.NotNil(t, response["fuel"])
 }
+
+// TestLegacyExoplanetWithoutMass reads back a gas giant stored without a mass
+// before it was required, through a migrated SQLite database
+func TestLegacyExoplanetWithoutMass(t *testing.T) {
+
+	loadEnvForTests()
+
+	dbConn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "legacy.db"))
+	require.NoError(t, err)
+	defer dbConn.Close()
+	previousDB, previousRepo := models.DB, exoplanetRepo
+	models.SetDB(dbConn)
+	models.SetDialect("sqlite")
+	defer func() {
+		models.SetDB(previousDB)
+		models.SetDialect(db.Driver())
+		SetRepository(previousRepo)
+	}()
+
+	require.NoError(t, models.MigrateTo(1))
+	result, err := dbConn.Exec(`INSERT INTO exoplanets (name, description, distance, radius, mass, type) VALUES ('Legacy Giant', 'Stored without a mass', 20, 11, 0, 'GasGiant')`)
+	require.NoError(t, err)
+	legacyID, err := result.LastInsertId()
+	require.NoError(t, err)
+	require.NoError(t, models.MigrateUp())
+	SetRepository(repository.NewSQLite(dbConn))
+	id := strconv.FormatInt(legacyID, 10)
+
+	get := func(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("GET", target, nil), map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get(GetExoplanetByID, "/exoplanets/"+id)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var planet map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &planet), rr.Body.String())
+	assert.Equal(t, true, planet["mass_unknown"])
+	assert.Equal(t, 0.0, planet["gravity"])
+	assert.Empty(t, planet["physics"])
+
+	rr = get(ListExoplanets, "/exoplanets")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var page struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page), rr.Body.String())
+	require.Len(t, page.Items, 1, rr.Body.String())
+	assert.Equal(t, "Legacy Giant", page.Items[0]["name"])
+
+	// Estimates that need the gravity are refused rather than infinite
+	rr = get(FuelEstimation, "/exoplanets/"+id+"/fuel?crewCapacity=5")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "mass")
+}
+
+// racingRepository lets another write land between each read and update of
+// the first patches it sees, as a concurrent editor would
+type racingRepository struct {
+	repository.ExoplanetRepository
+	races int
+}
+
+// Update first writes a competing change over the version being updated
+func (r *racingRepository) Update(ctx context.Context, exoplanet *models.Exoplanet) error {
+	if r.races > 0 {
+		r.races--
+		competing, err := r.ExoplanetRepository.GetByID(ctx, exoplanet.ID)
+		if err != nil {
+			return err
+		}
+		competing.Name += " (renamed)"
+		if err := r.ExoplanetRepository.Update(ctx, competing); err != nil {
+			return err
+		}
+	}
+	return r.ExoplanetRepository.Update(ctx, exoplanet)
+}
+
+// TestPatchExoplanetConcurrentWrite checks that a PATCH without If-Match is
+// applied over a write that lands meanwhile instead of failing a
+// precondition the client never set
+func TestPatchExoplanetConcurrentWrite(t *testing.T) {
+
+	loadEnvForTests()
+
+	exoplanet := models.Exoplanet{Name: "Busy", Description: "Often edited", Distance: 100, Radius: 2, Mass: 4, Type: models.Terrestrial}
+	require.NoError(t, exoplanetRepo.Create(context.Background(), &exoplanet))
+	id := strconv.Itoa(exoplanet.ID)
+
+	racing := &racingRepository{ExoplanetRepository: exoplanetRepo}
+	SetRepository(racing)
+	defer SetRepository(racing.ExoplanetRepository)
+
+	patch := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("PATCH", "/exoplanets/"+id, bytes.NewBufferString(`{"description": "Patched"}`)), map[string]string{"id": id})
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		PatchExoplanet(rr, req)
+		return rr
+	}
+
+	racing.races = 1
+	rr := patch(nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"name":"Busy (renamed)"`)
+	assert.Contains(t, rr.Body.String(), `"description":"Patched"`)
+
+	// Writes that never stop landing end in a conflict
+	racing.races = patchAttempts
+	rr = patch(nil)
+	assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+
+	// With If-Match the client asked to fail instead
+	current, err := exoplanetRepo.GetByID(context.Background(), exoplanet.ID)
+	require.NoError(t, err)
+	racing.races = 1
+	rr = patch(map[string]string{"If-Match": etag(current.Version, models.Astro)})
+	assert.Equal(t, http.StatusPreconditionFailed, rr.Code, rr.Body.String())
+}
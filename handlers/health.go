@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/problem"
+)
+
+// readinessDB is the database checked by Readyz, nil for the memory driver
+var readinessDB *sql.DB
+
+// readinessTimeout bounds each readiness check
+var readinessTimeout = 2 * time.Second
+
+// SetReadinessDB allows the main function to set the database that Readyz
+// pings and whose migration version it checks
+func SetReadinessDB(db *sql.DB) {
+	readinessDB = db
+}
+
+// SetReadinessTimeout sets how long Readyz waits for the database
+func SetReadinessTimeout(timeout time.Duration) {
+	readinessTimeout = timeout
+}
+
+// BuildInfo identifies the running build
+type BuildInfo struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// buildInfo is reported by Version
+var buildInfo = BuildInfo{Commit: "unknown", BuildTime: "unknown", GoVersion: runtime.Version()}
+
+// SetBuildInfo sets the commit and build time reported by Version. Empty
+// values fall back to the VCS stamp that go build embeds in the binary.
+func SetBuildInfo(commit, buildTime string) {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && commit == "":
+				commit = setting.Value
+			case setting.Key == "vcs.time" && buildTime == "":
+				buildTime = setting.Value
+			}
+		}
+	}
+	if commit != "" {
+		buildInfo.Commit = commit
+	}
+	if buildTime != "" {
+		buildInfo.BuildTime = buildTime
+	}
+}
+
+// Healthz reports that the process is alive. It checks no dependency, so
+// that a slow database does not get the service restarted.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// readiness is the body of a Readyz response
+type readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Readyz reports whether the service can serve traffic: the database answers
+// a ping and its schema is not behind the version this build expects. It
+// responds 503 when a check fails, e.g. until a database that was down at
+// startup comes up. A schema ahead of the build, as during a rollback, is
+// reported but ready.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	result := readiness{Status: "ready", Checks: map[string]string{}}
+
+	if readinessDB != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		if err := readinessDB.PingContext(ctx); err != nil {
+			result.Status = "unavailable"
+			result.Checks["database"] = err.Error()
+		} else {
+			result.Checks["database"] = "ok"
+
+			version, err := models.SchemaVersion(ctx)
+			switch {
+			case err != nil:
+				result.Status = "unavailable"
+				result.Checks["migrations"] = err.Error()
+			case version < models.LatestVersion():
+				result.Status = "unavailable"
+				result.Checks["migrations"] = fmt.Sprintf("schema at version %d, behind %d; run the migrate command or set DB_AUTO_MIGRATE", version, models.LatestVersion())
+			case version > models.LatestVersion():
+				result.Checks["migrations"] = fmt.Sprintf("schema at version %d, ahead of %d", version, models.LatestVersion())
+			default:
+				result.Checks["migrations"] = "ok"
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if result.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// versionResponse is the body of a Version response
+type versionResponse struct {
+	BuildInfo
+	// MigrationVersion is the applied schema version, null for the memory driver
+	MigrationVersion *int `json:"migration_version"`
+	LatestMigration  int  `json:"latest_migration"`
+}
+
+// Version reports the build and the applied migration version
+func Version(w http.ResponseWriter, r *http.Request) {
+	response := versionResponse{BuildInfo: buildInfo, LatestMigration: models.LatestVersion()}
+
+	if readinessDB != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		version, err := models.SchemaVersion(ctx)
+		if err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		response.MigrationVersion = &version
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
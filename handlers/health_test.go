@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+// TestReadyz checks readiness against a SQLite database as its schema and
+// connection change
+func TestReadyz(t *testing.T) {
+
+	loadEnvForTests()
+
+	dbConn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	models.SetDB(dbConn)
+	models.SetDialect("sqlite")
+	SetReadinessDB(dbConn)
+	defer func() {
+		SetReadinessDB(nil)
+		models.SetDB(nil)
+	}()
+
+	readyz := func() (int, readiness) {
+		rr := httptest.NewRecorder()
+		Readyz(rr, httptest.NewRequest("GET", "/readyz", nil))
+		var body readiness
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		return rr.Code, body
+	}
+
+	// Migrations pending
+	code, body := readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "ok", body.Checks["database"])
+	assert.Contains(t, body.Checks["migrations"], "behind")
+
+	require.NoError(t, models.MigrateUp())
+	code, body = readyz()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", body.Status)
+
+	// A schema ahead of the build, as after a rollback, stays ready
+	_, err = dbConn.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES (?, 'from_a_newer_build', '')`, models.LatestVersion()+1)
+	require.NoError(t, err)
+	code, body = readyz()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body.Checks["migrations"], "ahead")
+	_, err = dbConn.Exec(`DELETE FROM schema_migrations WHERE version = ?`, models.LatestVersion()+1)
+	require.NoError(t, err)
+
+	// /version reports the applied migration
+	rr := httptest.NewRecorder()
+	Version(rr, httptest.NewRequest("GET", "/version", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var version versionResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &version))
+	require.NotNil(t, version.MigrationVersion)
+	assert.Equal(t, models.LatestVersion(), *version.MigrationVersion)
+	assert.NotEmpty(t, version.GoVersion)
+
+	// Database gone
+	dbConn.Close()
+	code, body = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.NotEqual(t, "ok", body.Checks["database"])
+
+	// Liveness does not depend on the database
+	rr = httptest.NewRecorder()
+	Healthz(rr, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+// TestReadyzDatabaseComesUp checks that readiness recovers once a database
+// that was unreachable starts answering, without reopening the pool
+func TestReadyzDatabaseComesUp(t *testing.T) {
+
+	loadEnvForTests()
+
+	dir := filepath.Join(t.TempDir(), "not-yet")
+	dbConn, err := sql.Open("sqlite", filepath.Join(dir, "test.db"))
+	require.NoError(t, err)
+	defer dbConn.Close()
+	models.SetDB(dbConn)
+	models.SetDialect("sqlite")
+	SetReadinessDB(dbConn)
+	defer func() {
+		SetReadinessDB(nil)
+		models.SetDB(nil)
+	}()
+
+	rr := httptest.NewRecorder()
+	Readyz(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	require.NoError(t, os.Mkdir(dir, 0o700))
+	require.NoError(t, models.MigrateUp())
+	rr = httptest.NewRecorder()
+	Readyz(rr, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
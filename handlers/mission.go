@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/anilsaini81155/spacevoyagers/mission"
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/problem"
+	"github.com/gorilla/mux"
+)
+
+// PlanMission plans the delta-v and propellant for a crewed mission to an exoplanet
+/*
+	//sample request body
+	POST /exoplanets/1/missions/plan
+	{
+		"ship": {"dry_mass": 120000, "specific_impulse": 450, "payload_per_crew": 500},
+		"crew": 6,
+		"phases": ["departure", "cruise", "landing", "return"],
+		"cruise_speed": 30000
+	}
+
+	//or with a catalogued ship, whose cruise speed is used by default
+	{"ship_id": 1, "crew": 6}
+*/
+func PlanMission(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, _ := strconv.Atoi(params["id"])
+
+	var req mission.Request
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	exoplanet, err := exoplanetRepo.GetByID(r.Context(), id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	plan, ok := planMission(w, r, exoplanet, req)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// planMission plans req for target, flying the catalogued ship when
+// req.ShipID is set. On failure it writes the error response and returns false.
+func planMission(w http.ResponseWriter, r *http.Request, target *models.Exoplanet, req mission.Request) (*mission.Plan, bool) {
+	var plan *mission.Plan
+	var err error
+
+	if req.ShipID != 0 {
+		ship, shipErr := shipRepo.GetByID(r.Context(), req.ShipID)
+		if shipErr != nil {
+			problem.Error(w, r, shipErr)
+			return nil, false
+		}
+		plan, err = mission.PlanForShip(target, ship, req)
+	} else {
+		plan, err = mission.PlanMission(target, req)
+	}
+
+	if errors.Is(err, mission.ErrInfeasible) || errors.Is(err, mission.ErrExceedsLimits) {
+		problem.Write(w, r, http.StatusUnprocessableEntity, err.Error())
+		return nil, false
+	} else if err != nil {
+		problem.Error(w, r, err)
+		return nil, false
+	}
+	return plan, true
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPlanMission tests the PlanMission handler.
+func TestPlanMission(t *testing.T) {
+
+	loadEnvForTests()
+
+	exoplanet := models.Exoplanet{Name: "Mission Target", Description: "Plan target", Distance: 4.2, Radius: 1.1, Mass: 1.2, Type: models.Terrestrial}
+	if err := exoplanetRepo.Create(context.Background(), &exoplanet); err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.Itoa(exoplanet.ID)
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"ship": {"dry_mass": 120000, "specific_impulse": 8000, "payload_per_crew": 500}, "crew": 6, "cruise_speed": 30000}`, http.StatusOK},
+		{`{"ship": {"dry_mass": 120000, "specific_impulse": 450}, "crew": 0}`, http.StatusBadRequest},
+		{`{"ship": {"dry_mass": 120000, "specific_impulse": 450}, "crew": 2, "cruise_speed": 100000000}`, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", "/exoplanets/"+id+"/missions/plan", bytes.NewBufferString(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(PlanMission).ServeHTTP(rr, req)
+		t.Logf("Response Body: %s", rr.Body.String())
+
+		assert.Equal(t, tt.code, rr.Code)
+		if tt.code != http.StatusOK {
+			continue
+		}
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, response["phases"], 4)
+		assert.Greater(t, response["total_propellant_mass"], 0.0)
+	}
+}
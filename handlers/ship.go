@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/problem"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+	"github.com/gorilla/mux"
+)
+
+// shipRepo is the storage backend used by the ship handlers
+var shipRepo repository.ShipRepository
+
+// SetShipRepository allows the main function to set the ship storage backend
+func SetShipRepository(repo repository.ShipRepository) {
+	shipRepo = repo
+}
+
+// CreateShip handles adding a new ship to the catalog
+func CreateShip(w http.ResponseWriter, r *http.Request) {
+	var ship models.Ship
+	if !decodeJSON(w, r, &ship) {
+		return
+	}
+
+	if err := ship.Validate(); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if err := shipRepo.Create(r.Context(), &ship); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ship)
+}
+
+// ListShips handles listing the ship catalog
+func ListShips(w http.ResponseWriter, r *http.Request) {
+	ships, err := shipRepo.List(r.Context())
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if ships == nil {
+		ships = []models.Ship{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ships)
+}
+
+// GetShipByID handles fetching a ship by its ID
+func GetShipByID(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, _ := strconv.Atoi(params["id"])
+
+	ship, err := shipRepo.GetByID(r.Context(), id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(ship)
+}
+
+// UpdateShip handles updating a ship by its ID
+func UpdateShip(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, _ := strconv.Atoi(params["id"])
+
+	var updatedShip models.Ship
+	if !decodeJSON(w, r, &updatedShip) {
+		return
+	}
+
+	if err := updatedShip.Validate(); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	updatedShip.ID = id
+
+	if err := shipRepo.Update(r.Context(), &updatedShip); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(updatedShip)
+}
+
+// DeleteShip handles removing a ship by its ID
+func DeleteShip(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id, _ := strconv.Atoi(params["id"])
+
+	if err := shipRepo.Delete(r.Context(), id); err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
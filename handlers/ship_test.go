@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestShipCRUD tests the ship handlers end to end.
+func TestShipCRUD(t *testing.T) {
+
+	loadEnvForTests()
+
+	// Create
+	requestBody := `{"name": "Endurance", "dry_mass": 100000, "fuel_capacity": 900000, "specific_impulse": 8000, "max_crew": 8, "cruise_speed": 30000, "payload_per_crew": 500}`
+	req, _ := http.NewRequest("POST", "/ships", bytes.NewBufferString(requestBody))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(CreateShip).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var created models.Ship
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotZero(t, created.ID)
+	id := strconv.Itoa(created.ID)
+
+	// Invalid ships are rejected
+	req, _ = http.NewRequest("POST", "/ships", bytes.NewBufferString(`{"name": "Broken", "dry_mass": 1}`))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(CreateShip).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Update
+	req, _ = http.NewRequest("PUT", "/ships/"+id, bytes.NewBufferString(`{"name": "Endurance II", "dry_mass": 100000, "fuel_capacity": 900000, "specific_impulse": 8000, "max_crew": 8, "cruise_speed": 30000}`))
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(UpdateShip).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Get
+	req, _ = http.NewRequest("GET", "/ships/"+id, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetShipByID).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Endurance II")
+
+	// List
+	req, _ = http.NewRequest("GET", "/ships", nil)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(ListShips).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Endurance II")
+
+	// Delete
+	req, _ = http.NewRequest("DELETE", "/ships/"+id, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(DeleteShip).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	req, _ = http.NewRequest("GET", "/ships/"+id, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetShipByID).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+// TestFuelEstimationWithShip tests that ship limits are enforced by the fuel endpoint.
+func TestFuelEstimationWithShip(t *testing.T) {
+
+	loadEnvForTests()
+
+	ctx := context.Background()
+	exoplanet := models.Exoplanet{Name: "Ship Target", Description: "Fuel target", Distance: 4.2, Radius: 1, Mass: 1, Type: models.Terrestrial}
+	if err := exoplanetRepo.Create(ctx, &exoplanet); err != nil {
+		t.Fatal(err)
+	}
+	roomy := models.Ship{Name: "Roomy", DryMass: 100000, FuelCapacity: 1e9, SpecificImpulse: 8000, MaxCrew: 8, CruiseSpeed: 30000}
+	tiny := models.Ship{Name: "Tiny", DryMass: 100000, FuelCapacity: 10, SpecificImpulse: 8000, MaxCrew: 8, CruiseSpeed: 30000}
+	for _, ship := range []*models.Ship{&roomy, &tiny} {
+		if err := shipRepo.Create(ctx, ship); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		shipID  string
+		crew    string
+		code    int
+		message string
+	}{
+		{strconv.Itoa(roomy.ID), "5", http.StatusOK, `"fuel"`},
+		{strconv.Itoa(roomy.ID), "9", http.StatusUnprocessableEntity, "exceeds the maximum crew of 8"},
+		{strconv.Itoa(tiny.ID), "5", http.StatusUnprocessableEntity, "carries at most 10 kg"},
+		{"999999", "5", http.StatusNotFound, "ship not found"},
+		{"abc", "5", http.StatusBadRequest, "ship_id"},
+	}
+
+	id := strconv.Itoa(exoplanet.ID)
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/exoplanets/"+id+"/fuel?crewCapacity="+tt.crew+"&ship_id="+tt.shipID, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(FuelEstimation).ServeHTTP(rr, req)
+
+		assert.Equal(t, tt.code, rr.Code, rr.Body.String())
+		assert.Contains(t, rr.Body.String(), tt.message)
+	}
+}
@@ -1,69 +1,321 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/anilsaini81155/spacevoyagers/config"
 	"github.com/anilsaini81155/spacevoyagers/db"
 	"github.com/anilsaini81155/spacevoyagers/handlers"
+	"github.com/anilsaini81155/spacevoyagers/logging"
+	"github.com/anilsaini81155/spacevoyagers/metrics"
 	"github.com/anilsaini81155/spacevoyagers/middleware"
 	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+	"github.com/anilsaini81155/spacevoyagers/tracing"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
-	"golang.org/x/time/rate"
+)
+
+// Build metadata, set with -ldflags "-X main.commit=... -X main.buildTime=..."
+var (
+	commit    string
+	buildTime string
 )
 
 func main() {
+	// Load the configuration: defaults, config file, .env files, environment
+	// and flags, in increasing precedence
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	db.Configure(cfg.Database.Settings())
 
-	// Load environment variables
+	// Log as JSON; the log package's output is routed through the same logger
+	logLevel, _ := logging.ParseLevel(cfg.Log.Level)
+	logger := logging.New(os.Stdout, logLevel)
+	slog.SetDefault(logger)
 
-	// if os.Getenv("GO_ENV") != "TEST" {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file0")
+	// Export traces to the configured exporter (otlp, stdout or none)
+	shutdownTracing, tracingErr := tracing.Setup(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.File)
+	if tracingErr != nil {
+		log.Fatalf("Error initializing tracing: %v", tracingErr)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Subcommands run instead of the server
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(args[1:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
 	}
-	// } else {
-	// 	err := godotenv.Load(".envtest")
-	// 	if err != nil {
-	// 		log.Fatalf("Error loading .env test file")
-	// 	}
-	// })
-	// Get the application port from the environment variable
-	appPort := os.Getenv("APP_PORT")
-	if appPort == "" {
-		log.Fatalf("APP_PORT not set in .env file")
+	if len(args) > 0 && args[0] == "apikey" {
+		if err := runAPIKey(args[1:]); err != nil {
+			log.Fatalf("API key command failed: %v", err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "backfill" {
+		if err := runBackfill(args[1:]); err != nil {
+			log.Fatalf("Backfill failed: %v", err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "config" {
+		if err := runConfig(cfg, args[1:]); err != nil {
+			log.Fatalf("Config command failed: %v", err)
+		}
+		return
+	}
+	if len(args) > 0 {
+		log.Fatalf("Unknown command %q (want migrate, apikey, backfill or config)", args[0])
 	}
 
-	// Initialize the database connection (singleton)
-	dbConn, connerr := db.GetDB()
-	if connerr != nil {
-		log.Fatalf("Error initializing database: %v", connerr)
+	// Initialize the storage backend selected by DB_DRIVER (mysql, sqlite or
+	// memory). The database need not be reachable yet: /readyz reports 503
+	// until it is.
+	repos, repoErr := repository.Open(db.Driver())
+	if repoErr != nil {
+		log.Fatalf("Error initializing storage: %v", repoErr)
 	}
 
-	models.SetDB(dbConn)
+	if cfg.Auth.BootstrapKey != "" {
+		if err := seedBootstrapKey(context.Background(), repos.APIKeys, cfg.Auth.BootstrapKey); err != nil {
+			log.Fatalf("Error registering the bootstrap API key: %v", err)
+		}
+	}
 
-	// Run migrations (create tables)
-	models.RunMigrations()
-	limiter := rate.NewLimiter(rate.Every(1*time.Minute), 1) // 1 request every 60 seconds
+	handlers.SetRepository(repos.Exoplanets)
+	handlers.SetShipRepository(repos.Ships)
+	handlers.SetAPIKeyRepository(repos.APIKeys)
+	handlers.SetBuildInfo(commit, buildTime)
+	handlers.SetReadinessTimeout(cfg.Server.ReadinessTimeout)
+
+	// Export catalog gauges, and pool statistics for SQL drivers
+	if err := metrics.RegisterCatalog(repos.Exoplanets, repos.Ships); err != nil {
+		log.Fatalf("Error registering metrics: %v", err)
+	}
+	if db.Driver() != db.DriverMemory {
+		dbConn, err := db.GetDB()
+		if err != nil {
+			log.Fatalf("Error opening database connection: %v", err)
+		}
+		if err := metrics.RegisterDB(dbConn, db.Driver()); err != nil {
+			log.Fatalf("Error registering metrics: %v", err)
+		}
+		handlers.SetReadinessDB(dbConn)
+	}
+
+	// Callers without credentials get the anonymous role, or nothing when unset
+	anonymousRole := models.Role(cfg.Auth.AnonymousRole)
+	tokens, jwtErr := jwtValidator(cfg.Auth)
+	if jwtErr != nil {
+		log.Fatalf("Error initializing JWT validation: %v", jwtErr)
+	}
+
+	// Per-client rate limits, one policy per group of routes, sharing one store
+	limitStore, storeErr := rateLimitStore(cfg.RateLimit)
+	if storeErr != nil {
+		log.Fatalf("Error initializing rate limit store: %v", storeErr)
+	}
+	listLimit := rateLimit(limitStore, "list", cfg.RateLimit.List, middleware.RateLimiterMiddleware)
+	readLimit := rateLimit(limitStore, "read", cfg.RateLimit.Read, middleware.RateLimiterMiddleware)
+	writeLimit := rateLimit(limitStore, "write", cfg.RateLimit.Write, middleware.RateLimiterMiddleware)
+	planLimit := rateLimit(limitStore, "plan", cfg.RateLimit.Plan, middleware.RateLimiterMiddleware)
+	authLimit := rateLimit(limitStore, "auth", cfg.RateLimit.Auth, middleware.CredentialRateLimiterMiddleware)
 
 	// Create a new Gorilla Mux router
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(handlers.NotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(handlers.MethodNotAllowed)
 
 	// Apply middleware
-	r.Use(middleware.LoggingMiddleware) // Use logging middleware
-	r.Use(middleware.CORSMiddleware)    // Use CORS middleware
+	r.Use(tracing.RouteMiddleware)                                       // Name the request span after the route
+	r.Use(metrics.RouteMiddleware)                                       // Label request metrics with the route
+	r.Use(authLimit)                                                     // Throttle credentials per IP before looking them up
+	r.Use(middleware.Authenticate(repos.APIKeys, tokens, anonymousRole)) // Identify the caller
+
+	// Scraped by Prometheus and probed by the orchestrator without credentials
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	r.HandleFunc("/healthz", handlers.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", handlers.Readyz).Methods("GET")
+	r.HandleFunc("/version", handlers.Version).Methods("GET")
+
+	// handle registers a route with the role an API key needs, the scope a
+	// JWT needs ("" for none) and its rate limit
+	handle := func(path, method string, role models.Role, scope string, limit func(http.Handler) http.Handler, handler http.HandlerFunc) {
+		r.Handle(path, limit(middleware.Require(role, scope)(handler))).Methods(method)
+	}
+	const (
+		read  = middleware.ScopeExoplanetsRead
+		write = middleware.ScopeExoplanetsWrite
+		plan  = middleware.ScopeMissionsPlan
+	)
 
-	r.HandleFunc("/exoplanets", handlers.CreateExoplanet).Methods("POST")
+	handle("/exoplanets", "POST", models.RoleEditor, write, writeLimit, handlers.CreateExoplanet)
 	// r.HandleFunc("/exoplanets", handlers.ListExoplanets).Methods("GET")
-	r.Handle("/exoplanets", middleware.RateLimiterMiddleware(limiter)(http.HandlerFunc(handlers.ListExoplanets))).Methods("GET")
+	handle("/exoplanets", "GET", models.RoleViewer, read, listLimit, handlers.ListExoplanets)
 
-	r.HandleFunc("/exoplanets/{id}", handlers.GetExoplanetByID).Methods("GET")
-	r.HandleFunc("/exoplanets/{id}", handlers.UpdateExoplanet).Methods("PUT")
-	r.HandleFunc("/exoplanets/{id}", handlers.DeleteExoplanet).Methods("DELETE")
-	r.HandleFunc("/exoplanets/{id}/fuel", handlers.FuelEstimation).Methods("GET")
+	handle("/exoplanets/{id}", "GET", models.RoleViewer, read, readLimit, handlers.GetExoplanetByID)
+	handle("/exoplanets/{id}", "PUT", models.RoleEditor, write, writeLimit, handlers.UpdateExoplanet)
+	handle("/exoplanets/{id}", "PATCH", models.RoleEditor, write, writeLimit, handlers.PatchExoplanet)
+	handle("/exoplanets/{id}", "DELETE", models.RoleEditor, write, writeLimit, handlers.DeleteExoplanet)
+	handle("/exoplanets/{id}/fuel", "GET", models.RoleViewer, plan, planLimit, handlers.FuelEstimation)
+	handle("/exoplanets/{id}/missions/plan", "POST", models.RoleViewer, plan, planLimit, handlers.PlanMission)
 
-	log.Printf("Starting server on port %s...", appPort)
-	log.Fatal(http.ListenAndServe(":"+appPort, r))
+	handle("/ships", "POST", models.RoleEditor, write, writeLimit, handlers.CreateShip)
+	handle("/ships", "GET", models.RoleViewer, read, listLimit, handlers.ListShips)
+	handle("/ships/{id}", "GET", models.RoleViewer, read, readLimit, handlers.GetShipByID)
+	handle("/ships/{id}", "PUT", models.RoleEditor, write, writeLimit, handlers.UpdateShip)
+	handle("/ships/{id}", "DELETE", models.RoleEditor, write, writeLimit, handlers.DeleteShip)
+
+	handle("/admin/keys", "POST", models.RoleAdmin, "", writeLimit, handlers.IssueAPIKey)
+	handle("/admin/keys", "GET", models.RoleAdmin, "", listLimit, handlers.ListAPIKeys)
+	handle("/admin/keys/{id}", "DELETE", models.RoleAdmin, "", writeLimit, handlers.RevokeAPIKey)
+
+	// Tracing, metrics, logging and CORS wrap the router so that they also see
+	// requests that match no route, such as preflights
+	handler := tracing.Middleware(metrics.Middleware(middleware.LoggingMiddleware(logger)(middleware.CORSMiddleware(cfg.CORSConfig())(r))))
+
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Server.Port),
+		Handler:           handler,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	shutdownTimeout := cfg.Server.ShutdownTimeout
+
+	// Stop on SIGTERM (sent by orchestrators on deploy) or SIGINT (Ctrl-C)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Apply pending migrations only when asked to; otherwise the schema is
+	// managed with the migrate command and /readyz reports when it is behind
+	if cfg.Database.AutoMigrate && db.Driver() != db.DriverMemory {
+		dbConn, err := db.GetDB()
+		if err != nil {
+			log.Fatalf("Error opening database connection: %v", err)
+		}
+		go migrateInBackground(ctx, dbConn)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on port %d...", cfg.Server.Port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("Server failed: %v", err)
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process without waiting
+
+	// Stop accepting connections and wait for in-flight requests to finish
+	log.Printf("Shutting down, draining requests for up to %s...", shutdownTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(drainCtx); err != nil {
+		log.Printf("Requests still in flight after %s were cut off: %v", shutdownTimeout, err)
+		server.Close()
+	}
+
+	if db.Driver() != db.DriverMemory {
+		if dbConn, err := db.GetDB(); err == nil {
+			if err := dbConn.Close(); err != nil {
+				log.Printf("Error closing database connection: %v", err)
+			}
+		}
+	}
+	log.Printf("Server stopped")
+}
+
+// migrateInBackground waits, with backoff, for the database to answer and
+// then applies pending migrations, so that a database that is down at startup
+// delays readiness instead of stopping the server. A migration that fails
+// will not succeed on retry, so it stops the process.
+func migrateInBackground(ctx context.Context, dbConn *sql.DB) {
+	delay := time.Second
+	for {
+		err := dbConn.PingContext(ctx)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Database unreachable, retrying migrations in %s: %v", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, time.Minute)
+	}
+
+	if err := models.RunMigrations(); err != nil {
+		log.Fatalf("Error applying migrations: %v", err)
+	}
+	log.Printf("Migrations applied, schema at version %d", models.LatestVersion())
+}
+
+// jwtValidator builds the JWT validator for the JWKS file or URL in auth.
+// It returns nil when no JWKS is configured.
+func jwtValidator(auth config.Auth) (*middleware.JWTValidator, error) {
+	if auth.JWKS == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	keys, err := middleware.LoadJWKS(ctx, auth.JWKS, auth.JWKSRefresh)
+	if err != nil {
+		return nil, err
+	}
+	return middleware.NewJWTValidator(keys, auth.Issuer, auth.Audience, auth.ClockSkew), nil
+}
+
+// rateLimitStore opens the configured store: "memory" (per process, tracking
+// at most MaxClients keys) or "redis" at RedisURL, shared by every replica
+func rateLimitStore(limits config.RateLimit) (middleware.Store, error) {
+	if limits.Store == "redis" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return middleware.OpenRedisStore(ctx, limits.RedisURL)
+	}
+	return middleware.NewMemoryStore(limits.MaxClients), nil
+}
+
+// rateLimit builds the rate limit for a group of routes from its policy
+// (e.g. "60/1m:10", or "off"), already checked by config.Validate, applied
+// by newMiddleware
+func rateLimit(store middleware.Store, group, spec string, newMiddleware func(*middleware.RateLimiter) func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	if strings.EqualFold(spec, "off") {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	policy, err := middleware.ParsePolicy(spec)
+	if err != nil {
+		log.Fatalf("Invalid rate limit for %s: %v", group, err)
+	}
+	return newMiddleware(middleware.NewRateLimiter(group, policy, store))
 }
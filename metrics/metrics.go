@@ -0,0 +1,191 @@
+// Package metrics exposes the service's Prometheus metrics
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anilsaini81155/spacevoyagers/filter"
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "spacevoyagers"
+
+// Registry holds every metric served on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	rateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected with 429 by rate limit group.",
+	}, []string{"group"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		rateLimitRejections,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// RateLimitRejected counts a request rejected by a rate limit group
+func RateLimitRejected(group string) {
+	rateLimitRejections.WithLabelValues(group).Inc()
+}
+
+// statusRecorder captures the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// routeKey is the context key of the route template a request matched
+type routeKey struct{}
+
+// Middleware records the count and latency of each request under its route
+// template, e.g. /exoplanets/{id}, so that ids do not explode the label set.
+// It wraps the whole router so that requests matching no route, such as 404s,
+// 405s and preflights, are counted too, under "unknown"; RouteMiddleware
+// reports the route once it is known.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := "unknown"
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), routeKey{}, &route)))
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RouteMiddleware reports the matched route template to Middleware. Register
+// it with the router's Use.
+func RouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					*route = template
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RegisterDB exports the connection pool statistics of db
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// catalogCollector reports the size of the catalog, queried at scrape time
+type catalogCollector struct {
+	exoplanets repository.ExoplanetRepository
+	ships      repository.ShipRepository
+	timeout    time.Duration
+
+	exoplanetsDesc *prometheus.Desc
+	shipsDesc      *prometheus.Desc
+}
+
+// RegisterCatalog exports gauges of exoplanets by type and of ships
+func RegisterCatalog(exoplanets repository.ExoplanetRepository, ships repository.ShipRepository) error {
+	return Registry.Register(&catalogCollector{
+		exoplanets: exoplanets,
+		ships:      ships,
+		timeout:    5 * time.Second,
+		exoplanetsDesc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "exoplanets"),
+			"Catalogued exoplanets by type.", []string{"type"}, nil),
+		shipsDesc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "ships"),
+			"Catalogued ships.", nil, nil),
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *catalogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.exoplanetsDesc
+	ch <- c.shipsDesc
+}
+
+// Collect implements prometheus.Collector. Failed queries are logged and
+// their gauges left out of the scrape rather than reported as zero.
+func (c *catalogCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	for _, exoplanetType := range []models.ExoplanetType{models.Terrestrial, models.GasGiant} {
+		byType, err := filter.Compare("type", "eq", string(exoplanetType))
+		if err != nil {
+			continue
+		}
+		count, err := c.exoplanets.Count(ctx, repository.ListOptions{Filter: byType})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error counting exoplanets for metrics", "type", exoplanetType, "error", err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.exoplanetsDesc, prometheus.GaugeValue, float64(count), string(exoplanetType))
+	}
+
+	ships, err := c.ships.List(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing ships for metrics", "error", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.shipsDesc, prometheus.GaugeValue, float64(len(ships)))
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+// TestMetrics checks per-route request metrics and the exported gauges
+func TestMetrics(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(RouteMiddleware)
+	r.HandleFunc("/exoplanets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Exoplanet not found", http.StatusNotFound)
+	}).Methods("GET")
+	r.Handle("/metrics", Handler())
+	handler := Middleware(r)
+
+	for _, id := range []string{"1", "2", "3"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/exoplanets/"+id, nil))
+	}
+	assert.Equal(t, 3.0, testutil.ToFloat64(httpRequests.WithLabelValues("/exoplanets/{id}", "GET", "404")))
+	assert.Equal(t, 1, testutil.CollectAndCount(httpDuration, "spacevoyagers_http_request_duration_seconds"))
+
+	// Requests that match no route are counted too
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nowhere", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/exoplanets/1", nil))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequests.WithLabelValues("unknown", "GET", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequests.WithLabelValues("unknown", "DELETE", "405")))
+
+	RateLimitRejected("list")
+	assert.Equal(t, 1.0, testutil.ToFloat64(rateLimitRejections.WithLabelValues("list")))
+
+	// Catalog gauges and pool statistics
+	ctx := context.Background()
+	exoplanets, ships := repository.NewMemory(), repository.NewMemoryShips()
+	require.NoError(t, exoplanets.Create(ctx, &models.Exoplanet{Name: "A", Description: "a", Distance: 1, Radius: 1, Mass: 1, Type: models.Terrestrial}))
+	require.NoError(t, exoplanets.Create(ctx, &models.Exoplanet{Name: "B", Description: "b", Distance: 1, Radius: 1, Mass: 1, Type: models.Terrestrial}))
+	require.NoError(t, exoplanets.Create(ctx, &models.Exoplanet{Name: "C", Description: "c", Distance: 1, Radius: 11, Mass: 300, Type: models.GasGiant}))
+	require.NoError(t, RegisterCatalog(exoplanets, ships))
+
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, RegisterDB(db, "sqlite"))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	assert.Contains(t, body, `spacevoyagers_exoplanets{type="Terrestrial"} 2`)
+	assert.Contains(t, body, `spacevoyagers_exoplanets{type="GasGiant"} 1`)
+	assert.Contains(t, body, `spacevoyagers_ships 0`)
+	assert.Contains(t, body, `go_sql_max_open_connections{db_name="sqlite"}`)
+	assert.Contains(t, body, `spacevoyagers_http_requests_total{method="GET",route="/exoplanets/{id}",status="404"} 3`)
+	assert.Contains(t, body, `spacevoyagers_rate_limit_rejections_total{group="list"} 1`)
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/problem"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+)
+
+// APIKeyLookup finds an issued API key by the hash of its secret
+type APIKeyLookup interface {
+	GetByHash(ctx context.Context, hash string) (*models.APIKey, error)
+}
+
+// Principal is the authenticated caller of a request. API key callers hold a
+// Role; JWT callers hold the Scopes of their token instead.
+type Principal struct {
+	KeyID  int
+	Name   string
+	Role   models.Role
+	Scopes []string
+}
+
+// HasScope reports whether the caller's token grants scope
+func (p *Principal) HasScope(scope string) bool {
+	return scope != "" && slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the caller
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the caller stored by Authenticate, or nil
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// errInvalidCredentials is returned for unknown, revoked or malformed keys
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// credentials returns the API key sent in the X-API-Key header, or the
+// "Authorization: Bearer" token, which is an API key when it starts with
+// models.APIKeyPrefix and a JWT otherwise
+func credentials(r *http.Request) (apiKey, token string, err error) {
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+		return apiKey, "", nil
+	}
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return "", "", nil
+	}
+	bearer, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || bearer == "" {
+		return "", "", errInvalidCredentials
+	}
+	if strings.HasPrefix(bearer, models.APIKeyPrefix) {
+		return bearer, "", nil
+	}
+	return "", bearer, nil
+}
+
+// Authenticate resolves the API key or JWT of each request into a Principal.
+// JWTs are rejected when tokens is nil. Requests without credentials continue
+// as the anonymous role, or with no principal when anonymous is empty;
+// requests with bad credentials are rejected.
+func Authenticate(keys APIKeyLookup, tokens *JWTValidator, anonymous models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret, token, err := credentials(r)
+			if err != nil {
+				unauthorized(w, r, err.Error())
+				return
+			}
+
+			switch {
+			case token != "":
+				if tokens == nil {
+					unauthorized(w, r, errInvalidCredentials.Error())
+					return
+				}
+				principal, err := tokens.Validate(r.Context(), token)
+				if err != nil {
+					unauthorized(w, r, err.Error())
+					return
+				}
+				r = r.WithContext(WithPrincipal(r.Context(), principal))
+
+			case secret != "":
+				key, err := keys.GetByHash(r.Context(), models.HashAPIKey(secret))
+				if err != nil && !errors.Is(err, repository.ErrAPIKeyNotFound) {
+					problem.Error(w, r, fmt.Errorf("looking up API key: %w", err))
+					return
+				}
+				if err != nil || key.Revoked() {
+					unauthorized(w, r, errInvalidCredentials.Error())
+					return
+				}
+				r = r.WithContext(WithPrincipal(r.Context(), &Principal{KeyID: key.ID, Name: key.Name, Role: key.Role}))
+
+			case anonymous != "":
+				r = r.WithContext(WithPrincipal(r.Context(), &Principal{Name: "anonymous", Role: anonymous}))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Require rejects requests whose caller may not use a route: API key callers
+// need at least role, JWT callers need scope. An empty scope keeps JWTs out.
+func Require(role models.Role, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := PrincipalFromContext(r.Context())
+			if principal == nil {
+				unauthorized(w, r, "authentication required")
+				return
+			}
+			if principal.Role != "" && !principal.Role.Allows(role) {
+				problem.Write(w, r, http.StatusForbidden, "requires the "+string(role)+" role")
+				return
+			}
+			if principal.Role == "" && !principal.HasScope(scope) {
+				if scope == "" {
+					problem.Write(w, r, http.StatusForbidden, "not available to bearer tokens")
+				} else {
+					problem.Write(w, r, http.StatusForbidden, "requires the "+scope+" scope")
+				}
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// unauthorized writes a 401 naming the accepted scheme
+func unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="spacevoyagers"`)
+	problem.Write(w, r, http.StatusUnauthorized, message)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAuthenticateRoles checks credentials, roles and the anonymous role
+func TestAuthenticateRoles(t *testing.T) {
+	keys := repository.NewMemoryAPIKeys()
+	viewer, viewerSecret, err := models.NewAPIKey("reader", models.RoleViewer)
+	require.NoError(t, err)
+	require.NoError(t, keys.Create(context.Background(), &viewer))
+
+	call := func(anonymous, required models.Role, header, value string) (int, *Principal) {
+		var seen *Principal
+		handler := Authenticate(keys, nil, anonymous)(Require(required, "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = PrincipalFromContext(r.Context())
+		})))
+		req := httptest.NewRequest("GET", "/exoplanets", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code, seen
+	}
+
+	code, principal := call("", models.RoleViewer, "X-API-Key", viewerSecret)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, viewer.ID, principal.KeyID)
+
+	code, _ = call("", models.RoleEditor, "X-API-Key", viewerSecret)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _ = call("", models.RoleViewer, "Authorization", "Basic dXNlcjpwYXNz")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Anonymous callers get the configured role, but bad keys never fall back to it
+	code, principal = call(models.RoleViewer, models.RoleViewer, "", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "anonymous", principal.Name)
+	code, _ = call(models.RoleViewer, models.RoleViewer, "X-API-Key", "sv_unknown")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	assert.True(t, models.RoleAdmin.Allows(models.RoleEditor))
+	assert.False(t, models.Role("root").Allows(models.RoleViewer))
+}
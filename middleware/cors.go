@@ -1,19 +1,128 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// CORSMiddleware sets CORS headers for the application
-func CORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+// CORSConfig is the cross-origin policy of the API
+type CORSConfig struct {
+	// AllowedOrigins lists exact origins ("https://app.example.com"), origins
+	// with a wildcard subdomain ("https://*.example.com") or "*" for any
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Validate rejects policies that browsers would refuse or that are unsafe
+func (c CORSConfig) Validate() error {
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		return errors.New("CORS credentials cannot be allowed for every origin; list the origins instead")
+	}
+	for _, origin := range c.AllowedOrigins {
+		if strings.Count(origin, "*") > 1 || (origin != "*" && strings.Contains(origin, "*") && !strings.Contains(origin, "://*.")) {
+			return errors.New("CORS origin " + origin + " may only use a wildcard as the first subdomain, e.g. https://*.example.com")
+		}
+	}
+	return nil
+}
+
+// allowsOrigin reports whether origin matches one of the allowed origins
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		// "https://*.example.com" matches "https://api.example.com" but not
+		// "https://example.com"; the suffix keeps its leading dot
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok {
+			origin := strings.ToLower(origin)
+			prefix, suffix = strings.ToLower(prefix), strings.ToLower(suffix)
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+				!strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:@") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsHeaders reports whether every header of a preflight's
+// Access-Control-Request-Headers may be sent
+func (c CORSConfig) allowsHeaders(requested string) bool {
+	if slices.Contains(c.AllowedHeaders, "*") {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !slices.ContainsFunc(c.AllowedHeaders, func(allowed string) bool { return strings.EqualFold(allowed, header) }) {
+			return false
 		}
-		next.ServeHTTP(w, r)
-	})
+	}
+	return true
+}
+
+// CORSMiddleware applies the CORS policy. It must wrap the router rather than
+// be registered with Use, because preflight requests match no route.
+func CORSMiddleware(config CORSConfig) func(http.Handler) http.Handler {
+	wildcard := slices.Contains(config.AllowedOrigins, "*") && !config.AllowCredentials
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The response depends on the Origin, so caches must key on it
+			w.Header().Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			requestedMethod := r.Header.Get("Access-Control-Request-Method")
+			preflight := r.Method == http.MethodOptions && origin != "" && requestedMethod != ""
+
+			if origin == "" || !config.allowsOrigin(origin) {
+				if preflight {
+					// Answer without CORS headers; the browser blocks the request
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if wildcard {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if config.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(config.ExposedHeaders) > 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(config.ExposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			requestedHeaders := r.Header.Get("Access-Control-Request-Headers")
+			if slices.Contains(config.AllowedMethods, requestedMethod) && config.allowsHeaders(requestedHeaders) {
+				w.Header().Set("Access-Control-Allow-Methods", strings.Join(config.AllowedMethods, ", "))
+				if requestedHeaders != "" {
+					w.Header().Set("Access-Control-Allow-Headers", requestedHeaders)
+				}
+				if config.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
 }
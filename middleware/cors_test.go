@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCORSPolicy checks origin matching, credentials, exposed headers and preflights
+func TestCORSPolicy(t *testing.T) {
+	config := CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.voyagers.dev"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"Link", "Retry-After"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	reached := false
+	handler := CORSMiddleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	call := func(method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		reached = false
+		req := httptest.NewRequest(method, "/exoplanets", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Simple requests from allowed origins
+	for _, origin := range []string{"https://app.example.com", "https://api.voyagers.dev", "https://a.b.voyagers.dev"} {
+		rr := call("GET", origin, nil)
+		assert.True(t, reached)
+		assert.Equal(t, origin, rr.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "Link, Retry-After", rr.Header().Get("Access-Control-Expose-Headers"))
+		assert.Equal(t, "Origin", rr.Header().Get("Vary"))
+	}
+
+	// Other origins get no CORS headers
+	for _, origin := range []string{"https://evil.example.com", "https://voyagers.dev", "http://api.voyagers.dev", "https://evil.com/.voyagers.dev"} {
+		rr := call("GET", origin, nil)
+		assert.True(t, reached)
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"), origin)
+		assert.Equal(t, "Origin", rr.Header().Get("Vary"))
+	}
+
+	// A preflight is answered without reaching the handler
+	rr := call("OPTIONS", "https://app.example.com", map[string]string{
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type, authorization",
+	})
+	assert.False(t, reached)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "GET, POST", rr.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "content-type, authorization", rr.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rr.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, rr.Header().Values("Vary"))
+
+	// Disallowed methods and headers are not granted
+	rr = call("OPTIONS", "https://app.example.com", map[string]string{"Access-Control-Request-Method": "DELETE"})
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Methods"))
+	rr = call("OPTIONS", "https://app.example.com", map[string]string{"Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Secret"})
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Methods"))
+
+	// OPTIONS requests that are not preflights reach the handler
+	call("OPTIONS", "https://app.example.com", nil)
+	assert.True(t, reached)
+	call("OPTIONS", "", map[string]string{"Access-Control-Request-Method": "GET"})
+	assert.True(t, reached)
+}
+
+// TestCORSConfigValidate checks unsafe and malformed policies
+func TestCORSConfigValidate(t *testing.T) {
+	assert.NoError(t, CORSConfig{AllowedOrigins: []string{"*"}}.Validate())
+	assert.Error(t, CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}.Validate())
+	assert.Error(t, CORSConfig{AllowedOrigins: []string{"https://app.*.com"}}.Validate())
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	CORSMiddleware(CORSConfig{AllowedOrigins: []string{"*"}})(http.NotFoundHandler()).ServeHTTP(rr, req)
+	assert.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWKS is a set of public keys used to verify JWTs, loaded from a file or an
+// http(s) URL. The set is reloaded every refresh interval, and early when a
+// token names an unknown key id, so that rotated keys are picked up. Reloads
+// run in the background, one at a time, while requests keep using the cached
+// keys.
+type JWKS struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+	now     func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]jwk
+	loadedAt    time.Time
+	attemptedAt time.Time
+	reloading   chan struct{} // closed when the reload in flight finishes
+}
+
+// jwk is one verified public key of the set
+type jwk struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// minJWKSReload bounds how often unknown key ids can force a reload, so that
+// tokens with made-up key ids cannot hammer the JWKS endpoint, and how often
+// a failing endpoint is retried
+const minJWKSReload = 30 * time.Second
+
+// unknownKeyWait bounds how long a token with an unknown key id waits for the
+// reload it triggered, so that a slow JWKS endpoint cannot stall requests
+const unknownKeyWait = 2 * time.Second
+
+// LoadJWKS reads the key set at source, a file path or an http(s) URL
+func LoadJWKS(ctx context.Context, source string, refresh time.Duration) (*JWKS, error) {
+	set := &JWKS{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+	}
+	if err := set.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Reload fetches the key set from its source and replaces the current keys
+func (s *JWKS) Reload(ctx context.Context) error {
+	data, err := s.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("loading JWKS from %s: %w", s.source, err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("loading JWKS from %s: %w", s.source, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	s.loadedAt = s.now()
+	return nil
+}
+
+// Key returns the public key with the given id. A stale set is reloaded in
+// the background while its keys keep being served; an unknown id waits up to
+// unknownKeyWait for a reload. A failed reload keeps the previous keys.
+func (s *JWKS) Key(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	k, ok := s.keys[kid]
+	age := s.now().Sub(s.loadedAt)
+	var reloaded <-chan struct{}
+	if (!ok && age >= minJWKSReload) || (s.refresh > 0 && age >= s.refresh) {
+		reloaded = s.reloadInBackground()
+	}
+	s.mu.Unlock()
+
+	if !ok && reloaded != nil {
+		timer := time.NewTimer(unknownKeyWait)
+		defer timer.Stop()
+		select {
+		case <-reloaded:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		s.mu.Lock()
+		k, ok = s.keys[kid]
+		s.mu.Unlock()
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if k.alg != "" && k.alg != alg {
+		return nil, fmt.Errorf("key %q is for %s, not %s", kid, k.alg, alg)
+	}
+	return k.key, nil
+}
+
+// reloadInBackground starts a reload unless one is in flight or the last
+// attempt was too recent, and returns a channel closed when the reload in
+// flight finishes, or nil when there is none. s.mu must be held.
+func (s *JWKS) reloadInBackground() <-chan struct{} {
+	if s.reloading != nil {
+		return s.reloading
+	}
+	if s.now().Sub(s.attemptedAt) < minJWKSReload {
+		return nil
+	}
+
+	done := make(chan struct{})
+	s.reloading = done
+	s.attemptedAt = s.now()
+	go func() {
+		if err := s.Reload(context.Background()); err != nil {
+			slog.Warn("JWKS reload failed, keeping the previous keys", slog.String("error", err.Error()))
+		}
+		s.mu.Lock()
+		s.reloading = nil
+		s.mu.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// fetch reads the raw key set
+func (s *JWKS) fetch(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		return os.ReadFile(s.source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseJWKS decodes the RSA and P-256 signing keys of a JWK set (RFC 7517).
+// Keys of other types or uses are skipped.
+func parseJWKS(data []byte) (map[string]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]jwk)
+	for _, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		switch raw.Kty {
+		case "RSA":
+			n, errN := decodeBigInt(raw.N)
+			e, errE := decodeBigInt(raw.E)
+			if err := errors.Join(errN, errE); err != nil || !e.IsInt64() {
+				return nil, fmt.Errorf("key %q: invalid RSA parameters", raw.Kid)
+			}
+			key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			if raw.Crv != "P-256" {
+				continue
+			}
+			x, errX := decodeBigInt(raw.X)
+			y, errY := decodeBigInt(raw.Y)
+			if err := errors.Join(errX, errY); err != nil || !elliptic.P256().IsOnCurve(x, y) {
+				return nil, fmt.Errorf("key %q: invalid EC parameters", raw.Kid)
+			}
+			key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		default:
+			continue
+		}
+		keys[raw.Kid] = jwk{alg: raw.Alg, key: key}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no usable RSA or P-256 signing keys")
+	}
+	return keys, nil
+}
+
+// decodeBigInt decodes a base64url-encoded big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
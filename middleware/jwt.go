@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Scopes that JWTs carry to reach the API
+const (
+	ScopeExoplanetsRead  = "exoplanets:read"
+	ScopeExoplanetsWrite = "exoplanets:write"
+	ScopeMissionsPlan    = "missions:plan"
+)
+
+// JWTValidator verifies RS256 and ES256 bearer tokens against a JWKS
+type JWTValidator struct {
+	keys     *JWKS
+	issuer   string
+	audience string
+	skew     time.Duration
+	now      func() time.Time
+}
+
+// NewJWTValidator returns a validator for tokens signed by keys. Issuer and
+// audience are checked when not empty; skew is the clock difference tolerated
+// on exp, nbf and iat.
+func NewJWTValidator(keys *JWKS, issuer, audience string, skew time.Duration) *JWTValidator {
+	return &JWTValidator{keys: keys, issuer: issuer, audience: audience, skew: skew, now: time.Now}
+}
+
+// tokenClaims are the registered claims plus the scopes, which issuers send
+// either as a space-separated "scope" string or as an "scp" list
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope string   `json:"scope"`
+	Scp   []string `json:"scp"`
+}
+
+// Validate verifies a token and returns its caller
+func (v *JWTValidator) Validate(ctx context.Context, token string) (*Principal, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithLeeway(v.skew),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(v.now),
+	}
+	if v.issuer != "" {
+		options = append(options, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		options = append(options, jwt.WithAudience(v.audience))
+	}
+
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token has no key id")
+		}
+		return v.keys.Key(ctx, kid, t.Method.Alg())
+	}, options...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	scopes := append(strings.Fields(claims.Scope), claims.Scp...)
+	return &Principal{Name: claims.Subject, Scopes: scopes}, nil
+}
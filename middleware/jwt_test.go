@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jwkJSON encodes a public key as a JWK
+func jwkJSON(kid string, key interface{}) map[string]string {
+	b64 := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kid": kid, "kty": "RSA", "alg": "RS256", "use": "sig", "n": b64(key.N), "e": b64(big.NewInt(int64(key.E)))}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kid": kid, "kty": "EC", "crv": "P-256", "x": b64(key.X), "y": b64(key.Y)}
+	}
+	panic("unsupported key")
+}
+
+// writeJWKS writes a key set file holding the given public keys
+func writeJWKS(t *testing.T, path string, keys map[string]interface{}) {
+	set := map[string][]map[string]string{"keys": {}}
+	for kid, key := range keys {
+		set["keys"] = append(set["keys"], jwkJSON(kid, key))
+	}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+// sign issues a token with the given key and claims
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+// TestJWTValidation checks signatures, algorithms, claims, clock skew and key rotation
+func TestJWTValidation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rotated, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, map[string]interface{}{"rsa-1": &rsaKey.PublicKey, "ec-1": &ecKey.PublicKey})
+
+	now := time.Unix(1700000000, 0)
+	keys, err := LoadJWKS(context.Background(), path, time.Hour)
+	require.NoError(t, err)
+	keys.now = func() time.Time { return now }
+	require.NoError(t, keys.Reload(context.Background()))
+	validator := NewJWTValidator(keys, "https://issuer.example", "spacevoyagers", time.Minute)
+	validator.now = func() time.Time { return now }
+
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":   "https://issuer.example",
+			"aud":   "spacevoyagers",
+			"sub":   "mission-control",
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+			"scope": "exoplanets:read missions:plan",
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	principal, err := validator.Validate(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(nil)))
+	require.NoError(t, err)
+	assert.Equal(t, "mission-control", principal.Name)
+	assert.True(t, principal.HasScope(ScopeMissionsPlan))
+	assert.False(t, principal.HasScope(ScopeExoplanetsWrite))
+
+	principal, err = validator.Validate(context.Background(), sign(t, jwt.SigningMethodES256, "ec-1", ecKey, claims(jwt.MapClaims{"scope": nil, "scp": []string{"exoplanets:write"}})))
+	require.NoError(t, err)
+	assert.True(t, principal.HasScope(ScopeExoplanetsWrite))
+
+	// Expired within the clock skew is accepted, beyond it is not
+	_, err = validator.Validate(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"exp": now.Add(-30 * time.Second).Unix()})))
+	assert.NoError(t, err)
+	_, err = validator.Validate(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"exp": now.Add(-2 * time.Minute).Unix()})))
+	assert.ErrorContains(t, err, "expired")
+
+	for name, token := range map[string]string{
+		"wrong audience": sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"aud": "other"})),
+		"wrong issuer":   sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"iss": "https://evil.example"})),
+		"no expiry":      sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"exp": nil})),
+		"wrong key":      sign(t, jwt.SigningMethodES256, "ec-1", rotated, claims(nil)),
+		"alg mismatch":   sign(t, jwt.SigningMethodES256, "rsa-1", ecKey, claims(nil)),
+		"hmac":           sign(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret"), claims(nil)),
+		"unknown kid":    sign(t, jwt.SigningMethodES256, "ec-2", rotated, claims(nil)),
+	} {
+		_, err := validator.Validate(context.Background(), token)
+		assert.Error(t, err, name)
+	}
+
+	// After rotation the new key is picked up once the set may be reloaded
+	writeJWKS(t, path, map[string]interface{}{"ec-2": &rotated.PublicKey})
+	now = now.Add(minJWKSReload)
+	_, err = validator.Validate(context.Background(), sign(t, jwt.SigningMethodES256, "ec-2", rotated, claims(jwt.MapClaims{"iat": now.Unix(), "exp": now.Add(time.Hour).Unix()})))
+	assert.NoError(t, err)
+	_, err = validator.Validate(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(jwt.MapClaims{"iat": now.Unix(), "exp": now.Add(time.Hour).Unix()})))
+	assert.Error(t, err, "retired keys are dropped")
+}
+
+// TestJWTScopesOnRoutes checks that scopes, not roles, authorize JWT callers
+func TestJWTScopesOnRoutes(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	// Serve the key set over HTTP
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{jwkJSON("ec-1", &key.PublicKey)}})
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(jwks) }))
+	defer server.Close()
+
+	keys, err := LoadJWKS(context.Background(), server.URL, time.Hour)
+	require.NoError(t, err)
+	validator := NewJWTValidator(keys, "", "", time.Minute)
+
+	token := sign(t, jwt.SigningMethodES256, "ec-1", key, jwt.MapClaims{
+		"sub": "reader", "exp": time.Now().Add(time.Hour).Unix(), "scope": ScopeExoplanetsRead,
+	})
+	call := func(role models.Role, scope string) int {
+		handler := Authenticate(repository.NewMemoryAPIKeys(), validator, "")(Require(role, scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+		req := httptest.NewRequest("GET", "/exoplanets", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, call(models.RoleViewer, ScopeExoplanetsRead))
+	assert.Equal(t, http.StatusForbidden, call(models.RoleEditor, ScopeExoplanetsWrite))
+	assert.Equal(t, http.StatusForbidden, call(models.RoleAdmin, ""))
+
+	token += "x"
+	assert.Equal(t, http.StatusUnauthorized, call(models.RoleViewer, ScopeExoplanetsRead))
+}
+
+// TestJWKSSlowProvider checks that a slow JWKS endpoint delays no request:
+// cached keys are served while one background reload waits on the endpoint
+func TestJWKSSlowProvider(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rotated, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	initial, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{jwkJSON("ec-1", &key.PublicKey)}})
+	require.NoError(t, err)
+	both, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{jwkJSON("ec-1", &key.PublicKey), jwkJSON("ec-2", &rotated.PublicKey)}})
+	require.NoError(t, err)
+
+	// The first fetch is served at once, later ones hang until released
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 1 {
+			w.Write(initial)
+			return
+		}
+		<-release
+		w.Write(both)
+	}))
+	defer server.Close()
+
+	keys, err := LoadJWKS(context.Background(), server.URL, time.Minute)
+	require.NoError(t, err)
+	now := time.Now().Add(time.Hour)
+	keys.now = func() time.Time { return now }
+
+	// The set is stale: known keys are served from the cache without waiting
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		_, err := keys.Key(context.Background(), "ec-1", "ES256")
+		require.NoError(t, err)
+	}
+	assert.Less(t, time.Since(start), time.Second)
+
+	// Unknown keys wait no longer than the request allows
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = keys.Key(ctx, "ec-2", "ES256")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.EqualValues(t, 2, fetches.Load(), "one reload at a time")
+
+	// Once the endpoint answers, the reloaded keys are served
+	close(release)
+	assert.Eventually(t, func() bool {
+		_, err := keys.Key(context.Background(), "ec-2", "ES256")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
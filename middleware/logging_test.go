@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anilsaini81155/spacevoyagers/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoggingMiddleware checks the access log line and request ID propagation
+func TestLoggingMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(&out, slog.LevelInfo)
+
+	handler := LoggingMiddleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Lines logged while serving the request carry its ID
+		logger.ErrorContext(r.Context(), "Error querying exoplanets", "error", "no such table")
+		http.Error(w, "Error retrieving exoplanets", http.StatusInternalServerError)
+	}))
+
+	req := httptest.NewRequest("GET", "/exoplanets?limit=5", nil)
+	req.RemoteAddr = "10.0.0.7:4321"
+	req.Header.Set("X-Request-ID", "trace-123")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, "trace-123", rr.Header().Get("X-Request-ID"))
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 2)
+
+	assert.Equal(t, "Error querying exoplanets", lines[0]["msg"])
+	assert.Equal(t, "trace-123", lines[0]["request_id"])
+
+	access := lines[1]
+	assert.Equal(t, "request", access["msg"])
+	assert.Equal(t, "ERROR", access["level"])
+	assert.Equal(t, "trace-123", access["request_id"])
+	assert.Equal(t, "GET", access["method"])
+	assert.Equal(t, "/exoplanets", access["path"])
+	assert.Equal(t, "limit=5", access["query"])
+	assert.Equal(t, float64(500), access["status"])
+	assert.Equal(t, float64(len("Error retrieving exoplanets\n")), access["bytes"])
+	assert.Equal(t, "10.0.0.7", access["client_ip"])
+
+	// Missing or unusable IDs are replaced by a generated one
+	for _, sent := range []string{"", "has spaces", string(bytes.Repeat([]byte("a"), 129))} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", sent)
+		rr := httptest.NewRecorder()
+		LoggingMiddleware(logger)(http.NotFoundHandler()).ServeHTTP(rr, req)
+		assert.Len(t, rr.Header().Get("X-Request-ID"), 32)
+	}
+}
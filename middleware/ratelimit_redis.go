@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript runs gcra atomically in Redis, on the Redis clock so that
+// replicas agree on the time. Times are in microseconds. The key holds the
+// theoretical arrival time and expires once the bucket would be full.
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+local allow_at = new_tat - interval * burst
+
+if now < allow_at then
+	return {0, 0, allow_at - now}
+end
+
+redis.call("SET", KEYS[1], string.format("%.0f", new_tat), "PX", math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - allow_at) / interval), 0}
+`)
+
+// RedisStore keeps rate limit state in Redis (or anything speaking its
+// protocol), so that every replica shares one limit
+type RedisStore struct {
+	client redis.Scripter
+}
+
+// NewRedisStore returns a store backed by the given Redis client
+func NewRedisStore(client redis.Scripter) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// OpenRedisStore connects to the Redis server at url, e.g. redis://localhost:6379/0
+func OpenRedisStore(ctx context.Context, url string) (*RedisStore, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to redis: %w", err)
+	}
+	return NewRedisStore(client), nil
+}
+
+// Allow implements Store
+func (s *RedisStore) Allow(ctx context.Context, key string, policy Policy) (Decision, error) {
+	interval := policy.interval().Microseconds()
+	result, err := gcraScript.Run(ctx, s.client, []string{key}, interval, policy.Burst).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	if len(result) != 3 {
+		return Decision{}, fmt.Errorf("unexpected rate limit script result %v", result)
+	}
+
+	return Decision{
+		Allowed:    result[0] == 1,
+		Limit:      policy.Burst,
+		Remaining:  int(result[1]),
+		RetryAfter: time.Duration(result[2]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps rate limit state in process. Only the most recently seen
+// maxKeys keys are tracked; the least recently seen are evicted first, which
+// at worst hands an idle client a fresh bucket.
+type MemoryStore struct {
+	maxKeys int
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// memoryEntry is a list element value in MemoryStore.lru
+type memoryEntry struct {
+	key string
+	tat time.Time
+}
+
+// NewMemoryStore returns an in-process store that tracks at most maxKeys keys
+func NewMemoryStore(maxKeys int) *MemoryStore {
+	return &MemoryStore{
+		maxKeys: maxKeys,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Allow implements Store
+func (s *MemoryStore) Allow(ctx context.Context, key string, policy Policy) (Decision, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(key)
+	tat, decision := gcra(entry.tat, now, policy)
+	entry.tat = tat
+	return decision, nil
+}
+
+// entry returns the state for key, creating it and evicting the least
+// recently seen key when full. The caller holds s.mu.
+func (s *MemoryStore) entry(key string) *memoryEntry {
+	if element, ok := s.entries[key]; ok {
+		s.lru.MoveToFront(element)
+		return element.Value.(*memoryEntry)
+	}
+
+	if s.maxKeys > 0 && s.lru.Len() >= s.maxKeys {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).key)
+	}
+
+	entry := &memoryEntry{key: key}
+	s.entries[key] = s.lru.PushFront(entry)
+	return entry
+}
@@ -1,36 +1,194 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"golang.org/x/time/rate"
+	"github.com/anilsaini81155/spacevoyagers/metrics"
+	"github.com/anilsaini81155/spacevoyagers/problem"
 )
 
-// RateLimiterMiddleware applies rate limiting for incoming HTTP requests.
-func RateLimiterMiddleware(limiter *rate.Limiter) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// If the request exceeds the rate limit, return a 429 error
-			if !limiter.Allow() {
+// Policy is a token bucket: Requests tokens are added every Per, up to Burst
+type Policy struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// ParsePolicy parses a policy written as "<requests>/<duration>[:<burst>]",
+// e.g. "60/1m:10". The burst defaults to the number of requests.
+func ParsePolicy(s string) (Policy, error) {
+	spec, burstSpec, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	requestsSpec, perSpec, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("rate limit %q must look like 60/1m or 60/1m:10", s)
+	}
+
+	requests, err := strconv.Atoi(requestsSpec)
+	if err != nil || requests <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %q: requests must be a positive integer", s)
+	}
+	per, err := time.ParseDuration(perSpec)
+	if err != nil || per <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %q: invalid duration %q", s, perSpec)
+	}
+	burst := requests
+	if hasBurst {
+		burst, err = strconv.Atoi(burstSpec)
+		if err != nil || burst <= 0 {
+			return Policy{}, fmt.Errorf("rate limit %q: burst must be a positive integer", s)
+		}
+	}
+	return Policy{Requests: requests, Per: per, Burst: burst}, nil
+}
+
+// interval returns the time it takes to earn one request
+func (p Policy) interval() time.Duration {
+	return p.Per / time.Duration(p.Requests)
+}
+
+// String describes the policy as in the X-RateLimit-Policy header, e.g.
+// "60;w=60;burst=10" for 60 requests a minute with bursts of 10
+func (p Policy) String() string {
+	return fmt.Sprintf("%d;w=%d;burst=%d", p.Requests, int(math.Ceil(p.Per.Seconds())), p.Burst)
+}
+
+// ClientKey identifies the client a request is counted against: its API key
+// or token subject once authenticated, otherwise its IP address
+func ClientKey(r *http.Request) string {
+	if principal := PrincipalFromContext(r.Context()); principal != nil {
+		if principal.KeyID != 0 {
+			return "key:" + strconv.Itoa(principal.KeyID)
+		}
+		if principal.Role == "" && principal.Name != "" {
+			return "sub:" + principal.Name
+		}
+	}
+	return "ip:" + clientIP(r)
+}
+
+// clientIP returns the IP address the request came from
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Decision is the outcome of one rate limit check. Limit is the burst and
+// Remaining the requests that can be made at once.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Store keeps the rate limit state of every client. A store shared between
+// replicas makes the limit apply to the deployment rather than per process.
+type Store interface {
+	// Allow counts one request against key under policy
+	Allow(ctx context.Context, key string, policy Policy) (Decision, error)
+}
 
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
+// gcra applies the generic cell rate algorithm, the token bucket expressed
+// as a theoretical arrival time (tat): the time at which the bucket would be
+// full again. It returns the new tat, which is unchanged when denied.
+func gcra(tat, now time.Time, policy Policy) (time.Time, Decision) {
+	interval := policy.interval()
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-interval * time.Duration(policy.Burst))
+
+	decision := Decision{Limit: policy.Burst}
+	if now.Before(allowAt) {
+		decision.RetryAfter = allowAt.Sub(now)
+		return tat, decision
+	}
+	decision.Allowed = true
+	decision.Remaining = int(now.Sub(allowAt) / interval)
+	return newTat, decision
+}
+
+// RateLimiter applies one policy to a named group of routes
+type RateLimiter struct {
+	name   string
+	policy Policy
+	store  Store
+}
 
-				// Custom message with retry information
-				message := fmt.Sprintf(`{
-					"error": "Request limit exceeded",
-					"message": "You have exceeded the request limit. Please wait 60 seconds before trying again."
-				}`)
+// NewRateLimiter returns a per-client limiter for a group of routes. Groups
+// may share a store; their keys are kept apart by name.
+func NewRateLimiter(name string, policy Policy, store Store) *RateLimiter {
+	return &RateLimiter{name: name, policy: policy, store: store}
+}
+
+// Allow counts one request from a client
+func (l *RateLimiter) Allow(ctx context.Context, client string) (Decision, error) {
+	return l.store.Allow(ctx, "ratelimit:"+l.name+":"+client, l.policy)
+}
 
-				w.Write([]byte(message))
+// RateLimiterMiddleware applies a per-client rate limit to incoming HTTP requests.
+func RateLimiterMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit(limiter, ClientKey(r), w, r, next)
+		})
+	}
+}
 
-				// http.Error(w, "Too many requests", http.StatusTooManyRequests)
+// CredentialRateLimiterMiddleware limits requests carrying an API key or a
+// token per IP address. It runs before Authenticate so that guessing keys is
+// throttled before any of them is looked up; requests without credentials
+// pass through.
+func CredentialRateLimiterMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-API-Key") == "" && r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
 				return
 			}
-
-			// Call the next handler if the rate limit is not exceeded
-			next.ServeHTTP(w, r)
+			limit(limiter, "ip:"+clientIP(r), w, r, next)
 		})
 	}
 }
+
+// limit counts the request against client and either calls next or answers 429
+func limit(limiter *RateLimiter, client string, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	decision, err := limiter.Allow(r.Context(), client)
+	if err != nil {
+		// Fail open: an unavailable store should not take the API down
+		slog.ErrorContext(r.Context(), "Rate limiter unavailable, allowing request", "error", err)
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	// The limit is the configured rate; the burst, which bounds the
+	// remaining requests, is given by the policy header
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limiter.policy.Requests))
+	w.Header().Set("X-RateLimit-Policy", limiter.policy.String())
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+
+	// If the request exceeds the rate limit, return a 429 error
+	if !decision.Allowed {
+		metrics.RateLimitRejected(limiter.name)
+		retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		problem.Write(w, r, http.StatusTooManyRequests,
+			fmt.Sprintf("You have exceeded the request limit. Please wait %d seconds before trying again.", retryAfter))
+		return
+	}
+
+	// Call the next handler if the rate limit is not exceeded
+	next.ServeHTTP(w, r)
+}
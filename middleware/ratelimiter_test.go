@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/anilsaini81155/spacevoyagers/repository"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRateLimiterPerClient checks buckets, headers and Retry-After per client
+// against each store, with the Redis store talking to an in-process server
+func TestRateLimiterPerClient(t *testing.T) {
+	stores := map[string]func(t *testing.T, now *time.Time) (Store, func()){
+		"memory": func(t *testing.T, now *time.Time) (Store, func()) {
+			store := NewMemoryStore(100)
+			store.now = func() time.Time { return *now }
+			return store, func() {}
+		},
+		"redis": func(t *testing.T, now *time.Time) (Store, func()) {
+			server := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+			t.Cleanup(func() { client.Close() })
+			server.SetTime(*now)
+			return NewRedisStore(client), func() { server.SetTime(*now) }
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			store, tick := newStore(t, &now)
+			limiter := NewRateLimiter("list", Policy{Requests: 1, Per: 10 * time.Second, Burst: 2}, store)
+
+			handler := RateLimiterMiddleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			call := func(remoteAddr string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest("GET", "/exoplanets", nil)
+				req.RemoteAddr = remoteAddr
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				return rr
+			}
+
+			rr := call("10.0.0.1:1234")
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "1", rr.Header().Get("X-RateLimit-Limit"))
+			assert.Equal(t, "1;w=10;burst=2", rr.Header().Get("X-RateLimit-Policy"))
+			assert.Equal(t, "1", rr.Header().Get("X-RateLimit-Remaining"))
+
+			assert.Equal(t, http.StatusOK, call("10.0.0.1:1235").Code)
+
+			rr = call("10.0.0.1:1236")
+			assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+			assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+			assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+			assert.Contains(t, rr.Body.String(), "wait 10 seconds")
+
+			// Another client has its own bucket
+			assert.Equal(t, http.StatusOK, call("10.0.0.2:1234").Code)
+
+			now = now.Add(4 * time.Second)
+			tick()
+			assert.Equal(t, "6", call("10.0.0.1:1234").Header().Get("Retry-After"))
+
+			now = now.Add(6 * time.Second)
+			tick()
+			rr = call("10.0.0.1:1234")
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+		})
+	}
+}
+
+// TestRedisStoreIsShared checks that two limiters on one Redis share the limit
+func TestRedisStoreIsShared(t *testing.T) {
+	server := miniredis.RunT(t)
+	policy := Policy{Requests: 1, Per: time.Minute, Burst: 1}
+
+	var replicas []*RateLimiter
+	for i := 0; i < 2; i++ {
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		replicas = append(replicas, NewRateLimiter("plan", policy, NewRedisStore(client)))
+	}
+
+	decision, err := replicas[0].Allow(context.Background(), "ip:10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = replicas[1].Allow(context.Background(), "ip:10.0.0.1")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.InDelta(t, time.Minute, decision.RetryAfter, float64(time.Second))
+
+	server.Close()
+	_, err = replicas[0].Allow(context.Background(), "ip:10.0.0.1")
+	assert.Error(t, err)
+}
+
+// countingLookup counts the API keys looked up, none of which exist
+type countingLookup struct{ lookups int }
+
+func (c *countingLookup) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	c.lookups++
+	return nil, repository.ErrAPIKeyNotFound
+}
+
+// TestCredentialRateLimiter checks that guessed keys are throttled per IP
+// before they are looked up, and that anonymous requests are not counted
+func TestCredentialRateLimiter(t *testing.T) {
+	keys := &countingLookup{}
+	limiter := NewRateLimiter("auth", Policy{Requests: 1, Per: time.Minute, Burst: 3}, NewMemoryStore(100))
+	handler := CredentialRateLimiterMiddleware(limiter)(Authenticate(keys, nil, models.RoleViewer)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	call := func(remoteAddr, apiKey string) int {
+		req := httptest.NewRequest("GET", "/exoplanets", nil)
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, call("10.0.0.1:1234", fmt.Sprintf("svk_guess%d", i)))
+	}
+	for i := 3; i < 10; i++ {
+		assert.Equal(t, http.StatusTooManyRequests, call("10.0.0.1:1234", fmt.Sprintf("svk_guess%d", i)))
+	}
+	assert.Equal(t, 3, keys.lookups)
+
+	// Anonymous requests and other addresses are unaffected
+	assert.Equal(t, http.StatusOK, call("10.0.0.1:1234", ""))
+	assert.Equal(t, http.StatusUnauthorized, call("10.0.0.2:1234", "svk_guess"))
+	assert.Equal(t, 4, keys.lookups)
+}
+
+// TestMemoryStoreEvictsLeastRecentlySeen checks that only maxKeys keys are tracked
+func TestMemoryStoreEvictsLeastRecentlySeen(t *testing.T) {
+	store := NewMemoryStore(2)
+	policy := Policy{Requests: 1, Per: time.Hour, Burst: 1}
+	allowed := func(key string) bool {
+		decision, err := store.Allow(context.Background(), key, policy)
+		require.NoError(t, err)
+		return decision.Allowed
+	}
+
+	assert.True(t, allowed("a"))
+	assert.True(t, allowed("b"))
+	assert.False(t, allowed("a")) // a is now the most recently seen
+	assert.True(t, allowed("c"))  // evicts b
+	assert.Len(t, store.entries, 2)
+
+	assert.False(t, allowed("a"))
+	assert.True(t, allowed("b"), "b was evicted and starts with a full bucket")
+}
+
+// TestParsePolicy checks the policy syntax
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("60/1m:10")
+	require.NoError(t, err)
+	assert.Equal(t, Policy{Requests: 60, Per: time.Minute, Burst: 10}, policy)
+
+	policy, err = ParsePolicy("5/1s")
+	require.NoError(t, err)
+	assert.Equal(t, 5, policy.Burst)
+
+	for _, bad := range []string{"", "60", "0/1m", "60/forever", "60/1m:-1"} {
+		_, err := ParsePolicy(bad)
+		assert.Error(t, err, fmt.Sprintf("%q", bad))
+	}
+}
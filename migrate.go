@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/anilsaini81155/spacevoyagers/db"
+	"github.com/anilsaini81155/spacevoyagers/models"
+)
+
+const migrateUsage = "usage: spacevoyagers migrate up|down|status|to <version>"
+
+// runMigrate implements the `migrate` subcommand
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	driver := db.Driver()
+	if driver == db.DriverMemory {
+		return errors.New("the memory driver has no schema to migrate")
+	}
+
+	dbConn, err := db.GetDB()
+	if err != nil {
+		return err
+	}
+	models.SetDB(dbConn)
+	models.SetDialect(driver)
+
+	switch args[0] {
+	case "up":
+		return models.MigrateUp()
+	case "down":
+		return models.MigrateDown()
+	case "to":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid migration version %q", args[1])
+		}
+		return models.MigrateTo(version)
+	case "status":
+		return printMigrationStatus()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// printMigrationStatus writes a table of known migrations to stdout
+func printMigrationStatus() error {
+	statuses, err := models.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state := "pending"
+		if status.Applied {
+			state = "applied"
+		}
+		if status.Modified {
+			state = "modified"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, status.AppliedAt)
+	}
+	return w.Flush()
+}
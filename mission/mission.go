@@ -0,0 +1,222 @@
+package mission
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+)
+
+// StandardGravity converts specific impulse in seconds to exhaust velocity
+const StandardGravity = 9.80665 // m/s²
+
+// SpeedOfLight bounds the cruise speed
+const SpeedOfLight = models.SpeedOfLight
+
+// Mission phases, in the order they are flown by default
+const (
+	PhaseDeparture = "departure" // escape from Earth
+	PhaseCruise    = "cruise"    // accelerate to cruise speed, then brake at the target
+	PhaseLanding   = "landing"   // powered descent to the target's surface
+	PhaseReturn    = "return"    // ascent from the target and the cruise home
+)
+
+// DefaultPhases is used when a request lists no phases
+var DefaultPhases = []string{PhaseDeparture, PhaseCruise, PhaseLanding, PhaseReturn}
+
+// ErrInfeasible is returned when no finite amount of propellant can fly the mission
+var ErrInfeasible = errors.New("mission is not feasible with this ship")
+
+// ErrExceedsLimits is returned when a mission needs more crew or propellant
+// than a catalogued ship carries
+var ErrExceedsLimits = errors.New("mission exceeds the ship's limits")
+
+// Ship is the profile of the vehicle flying the mission. Masses are in kg.
+type Ship struct {
+	DryMass         float64 `json:"dry_mass"`
+	SpecificImpulse float64 `json:"specific_impulse"` // s
+	PayloadPerCrew  float64 `json:"payload_per_crew"`
+}
+
+// Request describes the mission to plan. ShipID selects a catalogued ship
+// instead of the inline Ship profile.
+type Request struct {
+	ShipID      int      `json:"ship_id,omitempty"`
+	Ship        Ship     `json:"ship"`
+	Crew        int      `json:"crew"`
+	Phases      []string `json:"phases,omitempty"`
+	CruiseSpeed float64  `json:"cruise_speed,omitempty"` // m/s, required for cruise and return
+}
+
+// PhasePlan is the delta-v and propellant of one mission phase
+type PhasePlan struct {
+	Phase          string  `json:"phase"`
+	DeltaV         float64 `json:"delta_v"`         // m/s
+	InitialMass    float64 `json:"initial_mass"`    // kg, at the start of the phase
+	FinalMass      float64 `json:"final_mass"`      // kg, at the end of the phase
+	PropellantMass float64 `json:"propellant_mass"` // kg
+}
+
+// Plan is the result of planning a mission
+type Plan struct {
+	ExoplanetID     int         `json:"exoplanet_id"`
+	ShipID          int         `json:"ship_id,omitempty"`
+	Crew            int         `json:"crew"`
+	Ship            Ship        `json:"ship"`
+	ExhaustVelocity float64     `json:"exhaust_velocity"` // m/s
+	PayloadMass     float64     `json:"payload_mass"`     // kg
+	Phases          []PhasePlan `json:"phases"`
+	TotalDeltaV     float64     `json:"total_delta_v"`         // m/s
+	TotalPropellant float64     `json:"total_propellant_mass"` // kg
+	InitialMass     float64     `json:"initial_mass"`          // kg, fully fuelled
+	CruiseYears     float64     `json:"cruise_duration_years,omitempty"`
+}
+
+// Validate checks the request before planning
+func (r *Request) Validate() error {
+	var errs models.ValidationError
+	if r.Ship.DryMass <= 0 {
+		errs.Add("ship.dry_mass", models.CodeOutOfRange, "must be positive")
+	}
+	if r.Ship.SpecificImpulse <= 0 {
+		errs.Add("ship.specific_impulse", models.CodeOutOfRange, "must be positive")
+	}
+	if r.Ship.PayloadPerCrew < 0 {
+		errs.Add("ship.payload_per_crew", models.CodeOutOfRange, "cannot be negative")
+	}
+	if r.Crew <= 0 {
+		errs.Add("crew", models.CodeOutOfRange, "must be positive")
+	}
+
+	seen := map[string]bool{}
+	for _, phase := range r.phases() {
+		switch phase {
+		case PhaseDeparture, PhaseLanding:
+		case PhaseCruise, PhaseReturn:
+			if r.CruiseSpeed <= 0 || r.CruiseSpeed >= SpeedOfLight {
+				errs.Add("cruise_speed", models.CodeOutOfRange, fmt.Sprintf("must be between 0 and the speed of light for the %s phase", phase))
+			}
+		default:
+			errs.Add("phases", models.CodeInvalidChoice, fmt.Sprintf("unknown mission phase %q", phase))
+		}
+		if seen[phase] {
+			errs.Add("phases", models.CodeInvalid, fmt.Sprintf("mission phase %q is repeated", phase))
+		}
+		seen[phase] = true
+	}
+	return errs.Err()
+}
+
+// phases returns the requested phases or the defaults
+func (r *Request) phases() []string {
+	if len(r.Phases) == 0 {
+		return DefaultPhases
+	}
+	return r.Phases
+}
+
+// DeltaV returns the velocity change a phase needs when flying to target.
+// Landing and ascent use the target's escape velocity, which assumes no
+// atmospheric braking; each cruise leg accelerates to and then brakes from the
+// cruise speed.
+func DeltaV(phase string, target *models.Exoplanet, cruiseSpeed float64) float64 {
+	switch phase {
+	case PhaseDeparture:
+		earth := models.Exoplanet{Radius: 1, Mass: 1, Type: models.Terrestrial}
+		return earth.EscapeVelocity()
+	case PhaseCruise:
+		return 2 * cruiseSpeed
+	case PhaseLanding:
+		return target.EscapeVelocity()
+	case PhaseReturn:
+		return target.EscapeVelocity() + 2*cruiseSpeed
+	}
+	return 0
+}
+
+// PlanMission applies the Tsiolkovsky rocket equation, m0 = mf·e^(Δv/ve),
+// phase by phase. It works backwards from the last phase, which must end with
+// the dry ship and its payload, so each earlier phase also carries the
+// propellant for every phase after it.
+func PlanMission(target *models.Exoplanet, req Request) (*Plan, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := target.RequireMass(); err != nil {
+		return nil, err
+	}
+
+	exhaustVelocity := req.Ship.SpecificImpulse * StandardGravity
+	payload := float64(req.Crew) * req.Ship.PayloadPerCrew
+	phases := req.phases()
+
+	plan := &Plan{
+		ExoplanetID:     target.ID,
+		Crew:            req.Crew,
+		Ship:            req.Ship,
+		ExhaustVelocity: exhaustVelocity,
+		PayloadMass:     payload,
+		Phases:          make([]PhasePlan, len(phases)),
+	}
+
+	finalMass := req.Ship.DryMass + payload
+	for i := len(phases) - 1; i >= 0; i-- {
+		deltaV := DeltaV(phases[i], target, req.CruiseSpeed)
+		initialMass := finalMass * math.Exp(deltaV/exhaustVelocity)
+		if math.IsInf(initialMass, 0) || math.IsNaN(initialMass) {
+			return nil, fmt.Errorf("%w: the %s phase needs a mass ratio beyond any real propellant load", ErrInfeasible, phases[i])
+		}
+
+		plan.Phases[i] = PhasePlan{
+			Phase:          phases[i],
+			DeltaV:         deltaV,
+			InitialMass:    initialMass,
+			FinalMass:      finalMass,
+			PropellantMass: initialMass - finalMass,
+		}
+		plan.TotalDeltaV += deltaV
+		finalMass = initialMass
+	}
+
+	plan.InitialMass = finalMass
+	plan.TotalPropellant = plan.InitialMass - req.Ship.DryMass - payload
+
+	if req.CruiseSpeed > 0 {
+		for _, phase := range phases {
+			if phase == PhaseCruise || phase == PhaseReturn {
+				plan.CruiseYears += target.Distance * models.LightYear / req.CruiseSpeed / secondsPerYear
+			}
+		}
+	}
+	return plan, nil
+}
+
+// PlanForShip plans a mission flown by a catalogued ship, using its profile
+// and, unless the request sets one, its cruise speed. Missions needing more
+// crew or propellant than the ship carries fail with ErrExceedsLimits.
+func PlanForShip(target *models.Exoplanet, ship *models.Ship, req Request) (*Plan, error) {
+	req.ShipID = ship.ID
+	req.Ship = Ship{DryMass: ship.DryMass, SpecificImpulse: ship.SpecificImpulse, PayloadPerCrew: ship.PayloadPerCrew}
+	if req.CruiseSpeed == 0 {
+		req.CruiseSpeed = ship.CruiseSpeed
+	}
+
+	if req.Crew > ship.MaxCrew {
+		return nil, fmt.Errorf("%w: a crew of %d exceeds the maximum crew of %d for ship %q", ErrExceedsLimits, req.Crew, ship.MaxCrew, ship.Name)
+	}
+
+	plan, err := PlanMission(target, req)
+	if err != nil {
+		return nil, err
+	}
+	plan.ShipID = ship.ID
+
+	if plan.TotalPropellant > ship.FuelCapacity {
+		return nil, fmt.Errorf("%w: the mission needs %.0f kg of propellant but ship %q carries at most %.0f kg", ErrExceedsLimits, plan.TotalPropellant, ship.Name, ship.FuelCapacity)
+	}
+	return plan, nil
+}
+
+// secondsPerYear is a Julian year, matching the light-year definition
+const secondsPerYear = 365.25 * 24 * 3600
@@ -0,0 +1,93 @@
+package mission
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var earthTwin = &models.Exoplanet{ID: 7, Name: "Twin", Distance: 4.2, Radius: 1, Mass: 1, Type: models.Terrestrial}
+
+// TestPlanMissionRocketEquation checks each phase against the Tsiolkovsky equation
+func TestPlanMissionRocketEquation(t *testing.T) {
+	req := Request{
+		Ship:        Ship{DryMass: 100000, SpecificImpulse: 10000, PayloadPerCrew: 1000},
+		Crew:        4,
+		CruiseSpeed: 20000,
+	}
+
+	plan, err := PlanMission(earthTwin, req)
+	require.NoError(t, err)
+	require.Len(t, plan.Phases, 4)
+
+	ve := 10000 * StandardGravity
+	assert.Equal(t, 4000.0, plan.PayloadMass)
+
+	// The last phase ends with the dry ship and payload
+	last := plan.Phases[3]
+	assert.Equal(t, PhaseReturn, last.Phase)
+	assert.Equal(t, 104000.0, last.FinalMass)
+	assert.InDelta(t, earthTwin.EscapeVelocity()+40000, last.DeltaV, 1e-6)
+
+	// Every phase satisfies m0 = mf·e^(Δv/ve) and feeds the phase before it
+	for i, phase := range plan.Phases {
+		assert.InDelta(t, phase.FinalMass*math.Exp(phase.DeltaV/ve), phase.InitialMass, 1e-6*phase.InitialMass)
+		assert.InDelta(t, phase.InitialMass-phase.FinalMass, phase.PropellantMass, 1e-6)
+		if i > 0 {
+			assert.Equal(t, plan.Phases[i-1].FinalMass, phase.InitialMass)
+		}
+	}
+
+	assert.InDelta(t, plan.InitialMass-104000, plan.TotalPropellant, 1e-6)
+	assert.InDelta(t, 2*4.2*models.LightYear/20000/secondsPerYear, plan.CruiseYears, 1e-6)
+}
+
+// TestPlanMissionValidation checks that bad requests are rejected
+func TestPlanMissionValidation(t *testing.T) {
+	valid := Request{Ship: Ship{DryMass: 1000, SpecificImpulse: 300}, Crew: 1, Phases: []string{PhaseLanding}}
+	_, err := PlanMission(earthTwin, valid)
+	require.NoError(t, err)
+
+	for name, mutate := range map[string]func(r *Request){
+		"no crew":           func(r *Request) { r.Crew = 0 },
+		"no dry mass":       func(r *Request) { r.Ship.DryMass = 0 },
+		"no isp":            func(r *Request) { r.Ship.SpecificImpulse = -1 },
+		"unknown phase":     func(r *Request) { r.Phases = []string{"warp"} },
+		"repeated phase":    func(r *Request) { r.Phases = []string{PhaseLanding, PhaseLanding} },
+		"cruise, no speed":  func(r *Request) { r.Phases = []string{PhaseCruise} },
+		"faster than light": func(r *Request) { r.Phases = []string{PhaseCruise}; r.CruiseSpeed = SpeedOfLight },
+	} {
+		req := valid
+		mutate(&req)
+		_, err := PlanMission(earthTwin, req)
+		assert.Error(t, err, name)
+	}
+}
+
+// TestPlanMissionInfeasible reports missions a chemical rocket cannot fly
+func TestPlanMissionInfeasible(t *testing.T) {
+	req := Request{Ship: Ship{DryMass: 1000, SpecificImpulse: 300}, Crew: 1, CruiseSpeed: 0.5 * SpeedOfLight}
+	_, err := PlanMission(earthTwin, req)
+	assert.True(t, errors.Is(err, ErrInfeasible))
+}
+
+// TestPlanForShipLimits checks the crew and fuel limits of a catalogued ship
+func TestPlanForShipLimits(t *testing.T) {
+	ship := &models.Ship{ID: 3, Name: "Ark", DryMass: 100000, FuelCapacity: 1e9, SpecificImpulse: 10000, MaxCrew: 4, CruiseSpeed: 20000}
+
+	plan, err := PlanForShip(earthTwin, ship, Request{Crew: 4})
+	require.NoError(t, err)
+	assert.Equal(t, 3, plan.ShipID)
+	assert.InDelta(t, 2*4.2*models.LightYear/20000/secondsPerYear, plan.CruiseYears, 1e-6)
+
+	_, err = PlanForShip(earthTwin, ship, Request{Crew: 5})
+	assert.ErrorIs(t, err, ErrExceedsLimits)
+
+	ship.FuelCapacity = plan.TotalPropellant - 1
+	_, err = PlanForShip(earthTwin, ship, Request{Crew: 4})
+	assert.ErrorIs(t, err, ErrExceedsLimits)
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Role grants access to a set of routes. Each role includes the ones below it.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+// roleRanks orders the roles from least to most privileged
+var roleRanks = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Allows reports whether a holder of r may use a route that requires required
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+// APIKeyPrefix starts every issued API key, so that keys are recognisable
+// in headers and secret scanners
+const APIKeyPrefix = "sv_"
+
+// APIKey is an issued API key. Only the SHA-256 hash of the secret is stored;
+// Prefix keeps its first characters so that a key can be recognised in lists.
+type APIKey struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"-"`
+	Role      Role       `json:"role"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// Validate ensures that the key details are correct
+func (k *APIKey) Validate() error {
+	var errs ValidationError
+	checkText(&errs, "name", k.Name, MaxNameLength, false)
+	if !k.Role.Valid() {
+		errs.Add("role", CodeInvalidChoice, fmt.Sprintf("must be %s, %s or %s", RoleViewer, RoleEditor, RoleAdmin))
+	}
+	return errs.Err()
+}
+
+// Revoked reports whether the key has been revoked
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// NewAPIKey generates a random secret for a key with the given name and role.
+// The secret is returned once and cannot be recovered from the key.
+func NewAPIKey(name string, role Role) (APIKey, string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return APIKey{}, "", err
+	}
+	secret := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+	return APIKeyFor(name, role, secret), secret, nil
+}
+
+// APIKeyFor returns the key for a secret chosen by the operator, such as the
+// bootstrap admin key
+func APIKeyFor(name string, role Role, secret string) APIKey {
+	return APIKey{
+		Name:      name,
+		Prefix:    secret[:min(len(secret), len(APIKeyPrefix)+6)],
+		Hash:      HashAPIKey(secret),
+		Role:      role,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// HashAPIKey returns the stored form of an API key secret. Secrets are long
+// and random, so a fast unsalted hash is enough to make a leaked table useless.
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// Sentinel errors that callers match with errors.Is to choose a response
+var (
+	// ErrNotFound is returned when a requested record does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrValidation is returned when input breaks a domain rule; the error
+	// is usually a *ValidationError naming the offending fields
+	ErrValidation = errors.New("validation failed")
+	// ErrConflict is returned when a change clashes with existing data, such
+	// as a duplicate unique value
+	ErrConflict = errors.New("conflict")
+	// ErrStale is returned when a conditional write names a version that is
+	// no longer current
+	ErrStale = errors.New("has changed since it was read")
+)
+
+// Validation codes name the rule a field broke, for clients to act on
+const (
+	CodeRequired      = "required"
+	CodeTooLong       = "too_long"
+	CodeOutOfRange    = "out_of_range"
+	CodeNotFinite     = "not_finite"
+	CodeInvalidChoice = "invalid_choice"
+	CodeInvalid       = "invalid"
+)
+
+// FieldError describes why one field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the invalid fields of an input. It matches
+// ErrValidation with errors.Is.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Field + ": " + field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is makes errors.Is(err, ErrValidation) hold
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// Add records that field broke the rule named by code
+func (e *ValidationError) Add(field, code, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Err returns e when a field was recorded, or nil
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// InvalidField returns a validation error for a single field
+func InvalidField(field, code, message string) error {
+	return &ValidationError{Fields: []FieldError{{Field: field, Code: code, Message: message}}}
+}
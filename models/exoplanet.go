@@ -1,11 +1,11 @@
 package models
 
 import (
-	"database/sql"
-	"errors"
-	_ "fmt"
+	"fmt"
+	"math"
+	"strings"
 
-	"github.com/anilsaini81155/spacevoyagers/db"
+	"github.com/anilsaini81155/spacevoyagers/units"
 )
 
 type ExoplanetType string
@@ -15,128 +15,86 @@ const (
 	Terrestrial ExoplanetType = "Terrestrial"
 )
 
+// Exoplanet is a catalogued planet. Fields are held in the canonical storage
+// units: Distance in light-years, Radius in Earth radii and Mass in Earth
+// masses, whatever the type. The JSON form is converted (see View).
 type Exoplanet struct {
-	ID          int           `json:"id"`
-	Name        string        `json:"name"`
-	Description string        `json:"description"`
-	Distance    float64       `json:"distance"`
-	Radius      float64       `json:"radius"`
-	Mass        float64       `json:"mass,omitempty"`
-	Type        ExoplanetType `json:"type"`
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Distance    float64 `json:"distance"`
+	Radius      float64 `json:"radius"`
+	// Mass is 0 when unknown, for gas giants stored before it was required;
+	// their gravity is 0 and nothing is derived from them
+	Mass float64       `json:"mass,omitempty"`
+	Type ExoplanetType `json:"type"`
+	// Gravity is the surface gravity in m/s². It is derived from the other
+	// fields and stored so that it can be filtered and sorted on; values sent
+	// by clients are ignored.
+	Gravity float64 `json:"gravity"`
+	// Version starts at 1 and is incremented on every write. It is the
+	// exoplanet's ETag, and a write that sets it only applies to that version.
+	Version int `json:"version"`
 }
 
-// AddExoplanet inserts a new exoplanet into the database
-func AddExoplanet(exoplanet *Exoplanet) error {
-
-	DB, err := db.GetDB() // Get singleton DB instance
-	if err != nil {
-		return err
-	}
-
-	query := `INSERT INTO exoplanets (name, description, distance, radius, mass, type) VALUES (?, ?, ?, ?, ?, ?)`
-	result, err := DB.Exec(query, exoplanet.Name, exoplanet.Description, exoplanet.Distance, exoplanet.Radius, exoplanet.Mass, exoplanet.Type)
-	if err != nil {
-		return err
-	}
-	id, _ := result.LastInsertId()
-	exoplanet.ID = int(id)
-	return nil
-}
-
-// GetAllExoplanets retrieves all exoplanets from the database
-func GetAllExoplanets() ([]Exoplanet, error) {
-
-	DB, dberr := db.GetDB() // Get singleton DB instance
-	if dberr != nil {
-		return nil, dberr
-	}
-
-	query := `SELECT id, name, description, distance, radius, mass, type FROM exoplanets`
-	rows, err := DB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var exoplanets []Exoplanet
-	for rows.Next() {
-		var exoplanet Exoplanet
-		if err := rows.Scan(&exoplanet.ID, &exoplanet.Name, &exoplanet.Description, &exoplanet.Distance, &exoplanet.Radius, &exoplanet.Mass, &exoplanet.Type); err != nil {
-			return nil, err
-		}
-		exoplanets = append(exoplanets, exoplanet)
-	}
-	return exoplanets, nil
-}
-
-// GetExoplanetByID retrieves a specific exoplanet by its ID
-func GetExoplanetByID(id int) (*Exoplanet, error) {
-
-	DB, dberr := db.GetDB() // Get singleton DB instance
-	if dberr != nil {
-		return nil, dberr
-	}
+// Validate ensures that the planet details are correct, reporting every
+// invalid field. Quantities are checked against the rules of the type.
+func (p *Exoplanet) Validate() error {
+	var errs ValidationError
+	checkText(&errs, "name", p.Name, MaxNameLength, false)
+	checkText(&errs, "description", p.Description, MaxDescriptionLength, true)
 
-	query := `SELECT id, name, description, distance, radius, mass, type FROM exoplanets WHERE id = ?`
-	var exoplanet Exoplanet
-	err := DB.QueryRow(query, id).Scan(&exoplanet.ID, &exoplanet.Name, &exoplanet.Description, &exoplanet.Distance, &exoplanet.Radius, &exoplanet.Mass, &exoplanet.Type)
-	if err == sql.ErrNoRows {
-		return nil, errors.New("exoplanet not found")
-	} else if err != nil {
-		return nil, err
+	rules, ok := RulesFor(p.Type)
+	if !ok {
+		errs.Add("type", CodeInvalidChoice, fmt.Sprintf("must be %s or %s", Terrestrial, GasGiant))
 	}
-	return &exoplanet, nil
-}
-
-// DeleteExoplanet removes an exoplanet from the database
-func DeleteExoplanet(id int) error {
-
-	DB, dberr := db.GetDB() // Get singleton DB instance
-	if dberr != nil {
-		return dberr
+	checkQuantity(&errs, "distance", p.Distance, storageDistanceUnit, rules.Distance, units.ConvertLength)
+	checkQuantity(&errs, "radius", p.Radius, storageRadiusUnit, rules.Radius, units.ConvertLength)
+	if p.Mass == 0 {
+		errs.Add("mass", CodeRequired, fmt.Sprintf("required for %s exoplanets", strings.ToLower(string(p.Type))))
+	} else {
+		checkQuantity(&errs, "mass", p.Mass, storageMassUnit, rules.Mass, units.ConvertMass)
 	}
-
-	query := `DELETE FROM exoplanets WHERE id = ?`
-	_, err := DB.Exec(query, id)
-	return err
+	return errs.Err()
 }
 
-// UpdateExoplanet updates an existing exoplanet in the database
-func UpdateExoplanet(exoplanet *Exoplanet) error {
-
-	DB, dberr := db.GetDB() // Get singleton DB instance
-	if dberr != nil {
-		return dberr
+// FuelPerCrewSQL computes FuelEstimation for a crew of one in SQL from the
+// stored gravity column. Exoplanets without a gravity (see Mass) get the
+// largest float64 instead of a division by zero, so that they sort after all
+// others with a value that cursors can hold.
+const FuelPerCrewSQL = "(CASE WHEN gravity > 0 THEN distance / (gravity * gravity) ELSE 1.7976931348623157e308 END)"
+
+// FuelPerCrew computes FuelPerCrewSQL from the stored Gravity, so that it
+// gives the same value as the database, bit for bit
+func (p *Exoplanet) FuelPerCrew() float64 {
+	if p.Gravity <= 0 {
+		return math.MaxFloat64
 	}
-
-	query := `UPDATE exoplanets SET name = ?, description = ?, distance = ?, radius = ?, mass = ?, type = ? WHERE id = ?`
-	_, err := DB.Exec(query, exoplanet.Name, exoplanet.Description, exoplanet.Distance, exoplanet.Radius, exoplanet.Mass, exoplanet.Type, exoplanet.ID)
-	return err
+	return p.Distance / (p.Gravity * p.Gravity)
 }
 
-// Validate ensures that the planet details are correct
-func (p *Exoplanet) Validate() error {
-	if p.Name == "" || p.Description == "" || p.Distance <= 0 || p.Radius <= 0 {
-		return errors.New("invalid exoplanet data")
-	}
-	if p.Type == Terrestrial && p.Mass <= 0 {
-		return errors.New("mass required for terrestrial exoplanets")
+// RequireMass fails for an exoplanet whose mass is unknown, as estimates
+// that depend on its gravity cannot be made
+func (p *Exoplanet) RequireMass() error {
+	if p.Mass <= 0 {
+		return InvalidField("mass", CodeRequired, "unknown for this exoplanet; set it with PUT or PATCH first")
 	}
 	return nil
 }
 
-// CalculateGravity returns the gravity of the exoplanet based on type
+// CalculateGravity returns the surface gravity in m/s², G·M/R²
 func (p *Exoplanet) CalculateGravity() float64 {
-	if p.Type == GasGiant {
-		return 0.5 / (p.Radius * p.Radius)
-	}
-	return p.Mass / (p.Radius * p.Radius)
+	radius := p.RadiusMeters()
+	return GravitationalConstant * p.MassKg() / (radius * radius)
 }
 
 // FuelEstimation calculates the fuel based on distance, gravity, and crew capacity
 func (p *Exoplanet) FuelEstimation(crewCapacity int) (float64, error) {
 	if crewCapacity <= 0 {
-		return 0, errors.New("invalid crew capacity")
+		return 0, InvalidField("crewCapacity", CodeOutOfRange, "must be a positive integer")
+	}
+	if err := p.RequireMass(); err != nil {
+		return 0, err
 	}
 	gravity := p.CalculateGravity()
 	fuel := (p.Distance / (gravity * gravity)) * float64(crewCapacity)
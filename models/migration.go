@@ -1,30 +1,98 @@
 package models
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
-// Migration represents a migration script with a name and SQL query.
+// Migration is a numbered, reversible schema change. Up and Down hold MySQL
+// statements separated by semicolons; SQLiteUp and SQLiteDown override them
+// when the MySQL syntax is not portable.
 type Migration struct {
-	Name  string
-	Query string
+	Version    int
+	Name       string
+	Up         string
+	Down       string
+	SQLiteUp   string
+	SQLiteDown string
+
+	// Applied optionally reports whether the change is already present in the
+	// schema, for DDL that cannot be written idempotently. Up is skipped when
+	// it returns true and Down is skipped when it returns false.
+	Applied func(ctx context.Context, tx *sql.Tx) (bool, error)
+}
+
+// MigrationStatus describes whether a known migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt string
+	// Modified is set when the applied checksum differs from the code
+	Modified bool
 }
 
 // Global DB variable to hold the database connection
 var DB *sql.DB
 
+// tracer traces migration runs; their statements are children of its spans
+var tracer = otel.Tracer("github.com/anilsaini81155/spacevoyagers/models")
+
+// Dialect is the SQL dialect of DB, either "mysql" or "sqlite"
+var Dialect = "mysql"
+
 // SetDB allows the main function to set the global DB instance
 func SetDB(db *sql.DB) {
 	DB = db
 }
 
+// SetDialect selects the SQL dialect used by the migrations
+func SetDialect(dialect string) {
+	Dialect = dialect
+}
+
+// up returns the forward statements for the active dialect
+func (m Migration) up() string {
+	if Dialect == "sqlite" && m.SQLiteUp != "" {
+		return m.SQLiteUp
+	}
+	return m.Up
+}
+
+// down returns the rollback statements for the active dialect
+func (m Migration) down() string {
+	if Dialect == "sqlite" && m.SQLiteDown != "" {
+		return m.SQLiteDown
+	}
+	return m.Down
+}
+
+// Checksum fingerprints the SQL of the migration for the active dialect so
+// edits to an already applied migration can be detected
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.up() + "\x00" + m.down()))
+	return hex.EncodeToString(sum[:])
+}
+
+// migrations must be listed in ascending version order and never edited once
+// released; add a new migration instead
 var migrations = []Migration{
 	{
-		Name: "create_exoplanets_table",
-		Query: `
+		Version: 1,
+		Name:    "create_exoplanets_table",
+		Up: `
             CREATE TABLE IF NOT EXISTS exoplanets (
                 id INT AUTO_INCREMENT,
                 name VARCHAR(255) NOT NULL,
@@ -35,78 +103,621 @@ var migrations = []Migration{
                 type VARCHAR(50),
                 PRIMARY KEY (id)
             );
+        `,
+		SQLiteUp: `
+            CREATE TABLE IF NOT EXISTS exoplanets (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                name VARCHAR(255) NOT NULL,
+                description TEXT,
+                distance FLOAT,
+                radius FLOAT,
+                mass FLOAT DEFAULT NULL,
+                type VARCHAR(50)
+            );
+        `,
+		Down: `
+            DROP TABLE IF EXISTS exoplanets;
         `,
 	},
 	{
-		Name: "add_gravity_column_to_exoplanets",
-		Query: `
+		Version: 2,
+		Name:    "add_gravity_column_to_exoplanets",
+		Up: `
             ALTER TABLE exoplanets ADD COLUMN gravity FLOAT DEFAULT NULL;
         `,
+		Down: `
+            ALTER TABLE exoplanets DROP COLUMN gravity;
+        `,
+		Applied: func(ctx context.Context, tx *sql.Tx) (bool, error) {
+			return columnExists(ctx, tx, "exoplanets", "gravity")
+		},
 	},
+	{
+		Version: 3,
+		Name:    "add_gravity_index_to_exoplanets",
+		Up: `
+            CREATE INDEX idx_exoplanets_gravity ON exoplanets (gravity);
+        `,
+		Down: `
+            DROP INDEX idx_exoplanets_gravity ON exoplanets;
+        `,
+		SQLiteUp: `
+            CREATE INDEX IF NOT EXISTS idx_exoplanets_gravity ON exoplanets (gravity);
+        `,
+		SQLiteDown: `
+            DROP INDEX IF EXISTS idx_exoplanets_gravity;
+        `,
+	},
+	{
+		Version: 4,
+		Name:    "create_ships_table",
+		Up: `
+            CREATE TABLE IF NOT EXISTS ships (
+                id INT AUTO_INCREMENT,
+                name VARCHAR(255) NOT NULL,
+                dry_mass DOUBLE NOT NULL,
+                fuel_capacity DOUBLE NOT NULL,
+                specific_impulse DOUBLE NOT NULL,
+                max_crew INT NOT NULL,
+                cruise_speed DOUBLE NOT NULL,
+                payload_per_crew DOUBLE NOT NULL DEFAULT 0,
+                PRIMARY KEY (id)
+            );
+        `,
+		SQLiteUp: `
+            CREATE TABLE IF NOT EXISTS ships (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                name VARCHAR(255) NOT NULL,
+                dry_mass DOUBLE NOT NULL,
+                fuel_capacity DOUBLE NOT NULL,
+                specific_impulse DOUBLE NOT NULL,
+                max_crew INT NOT NULL,
+                cruise_speed DOUBLE NOT NULL,
+                payload_per_crew DOUBLE NOT NULL DEFAULT 0
+            );
+        `,
+		Down: `
+            DROP TABLE IF EXISTS ships;
+        `,
+	},
+	{
+		// Gas giants used to be stored in Jupiter units; every type is now
+		// stored in Earth radii and Earth masses (JupiterRadius/EarthRadius and
+		// JupiterMass/EarthMass).
+		Version: 5,
+		Name:    "store_gas_giants_in_earth_units",
+		Up: `
+            UPDATE exoplanets SET radius = radius * 10.973316590802073, mass = mass * 317.8276012189813 WHERE type = 'GasGiant';
+        `,
+		Down: `
+            UPDATE exoplanets SET radius = radius / 10.973316590802073, mass = mass / 317.8276012189813 WHERE type = 'GasGiant';
+        `,
+	},
+	{
+		// Times are unix seconds so that both dialects scan them the same way
+		Version: 6,
+		Name:    "create_api_keys_table",
+		Up: `
+            CREATE TABLE IF NOT EXISTS api_keys (
+                id INT AUTO_INCREMENT,
+                name VARCHAR(255) NOT NULL,
+                prefix VARCHAR(16) NOT NULL,
+                key_hash CHAR(64) NOT NULL,
+                role VARCHAR(16) NOT NULL,
+                created_at BIGINT NOT NULL,
+                revoked_at BIGINT NULL,
+                PRIMARY KEY (id),
+                UNIQUE KEY idx_api_keys_hash (key_hash)
+            );
+        `,
+		SQLiteUp: `
+            CREATE TABLE IF NOT EXISTS api_keys (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                name VARCHAR(255) NOT NULL,
+                prefix VARCHAR(16) NOT NULL,
+                key_hash CHAR(64) NOT NULL UNIQUE,
+                role VARCHAR(16) NOT NULL,
+                created_at BIGINT NOT NULL,
+                revoked_at BIGINT NULL
+            );
+        `,
+		Down: `
+            DROP TABLE IF EXISTS api_keys;
+        `,
+	},
+	{
+		// Incremented on every write, for optimistic concurrency
+		Version: 7,
+		Name:    "add_version_column_to_exoplanets",
+		Up: `
+            ALTER TABLE exoplanets ADD COLUMN version INT NOT NULL DEFAULT 1;
+        `,
+		Down: `
+            ALTER TABLE exoplanets DROP COLUMN version;
+        `,
+		Applied: func(ctx context.Context, tx *sql.Tx) (bool, error) {
+			return columnExists(ctx, tx, "exoplanets", "version")
+		},
+	},
+	{
+		// MySQL FLOAT columns are single precision, so the float64 values of
+		// a pagination cursor never compare equal to them. Values already
+		// stored keep their single precision rounding until rewritten.
+		Version: 8,
+		Name:    "store_exoplanet_quantities_as_double",
+		Up: `
+            ALTER TABLE exoplanets MODIFY distance DOUBLE, MODIFY radius DOUBLE, MODIFY mass DOUBLE DEFAULT NULL, MODIFY gravity DOUBLE DEFAULT NULL;
+        `,
+		Down: `
+            ALTER TABLE exoplanets MODIFY distance FLOAT, MODIFY radius FLOAT, MODIFY mass FLOAT DEFAULT NULL, MODIFY gravity FLOAT DEFAULT NULL;
+        `,
+		SQLiteUp: `
+            -- SQLite already stores FLOAT columns as 8-byte REAL values
+        `,
+		SQLiteDown: `
+            -- SQLite already stores FLOAT columns as 8-byte REAL values
+        `,
+	},
+	{
+		// Sorting and paging by gravity or fuel compare the stored gravity,
+		// so rows without it would drop out of every page after the first.
+		// Gravity is G·M/R² with mass and radius in Earth units; SQLite
+		// cannot add a constraint to an existing column, so there the rows
+		// are only filled.
+		Version: 9,
+		Name:    "require_gravity_on_exoplanets",
+		Up: `
+            UPDATE exoplanets SET gravity = 6.6743e-11 * (mass * 5.9722e24) / ((radius * 6.371e6) * (radius * 6.371e6)), version = version + 1 WHERE gravity IS NULL;
+            ALTER TABLE exoplanets MODIFY gravity DOUBLE NOT NULL;
+        `,
+		Down: `
+            ALTER TABLE exoplanets MODIFY gravity DOUBLE DEFAULT NULL;
+        `,
+		SQLiteUp: `
+            UPDATE exoplanets SET gravity = 6.6743e-11 * (mass * 5.9722e24) / ((radius * 6.371e6) * (radius * 6.371e6)), version = version + 1 WHERE gravity IS NULL;
+        `,
+		SQLiteDown: `
+            -- The SQLite column never had the constraint
+        `,
+	},
+	{
+		// Gas giants could be stored without a mass before it was required.
+		// Their mass cannot be recovered, so they are marked as unknown with
+		// a mass and gravity of 0, which nothing is derived from.
+		Version: 10,
+		Name:    "flag_exoplanets_without_mass",
+		Up: `
+            UPDATE exoplanets SET mass = 0, gravity = 0, version = version + 1 WHERE mass IS NULL OR mass < 0 OR (mass = 0 AND gravity <> 0);
+        `,
+		Down: `
+            DO 0;
+        `,
+		SQLiteDown: `
+            -- Rows without a mass stay flagged
+        `,
+	},
+}
+
+// LatestVersion returns the version of the newest known migration
+func LatestVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
 }
 
 // RunMigrations applies all pending migrations
-func RunMigrations() {
+func RunMigrations() error {
+	return MigrateUp()
+}
 
-	if DB == nil {
-		panic("database connection is not initialized")
+// MigrateUp applies every pending migration in version order
+func MigrateUp() error {
+	return MigrateTo(LatestVersion())
+}
+
+// MigrateDown rolls back the most recently applied migration
+func MigrateDown() error {
+	current, err := CurrentVersion()
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return errors.New("no migrations to roll back")
 	}
 
+	target := 0
 	for _, migration := range migrations {
-		applyMigration(migration)
+		if migration.Version < current {
+			target = migration.Version
+		}
 	}
+	return MigrateTo(target)
 }
 
-// applyMigration runs a specific migration if it hasn't been applied yet
-func applyMigration(migration Migration) {
-	if !hasMigrationBeenApplied(migration.Name) {
-		_, err := DB.Exec(migration.Query)
+// MigrateTo applies or rolls back migrations until the schema is at version
+func MigrateTo(version int) (err error) {
+	if version != 0 && findMigration(version) == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	ctx, span := tracer.Start(context.Background(), "MigrateTo")
+	span.SetAttributes(attribute.Int("migration.target_version", version))
+	defer func() {
 		if err != nil {
-			log.Fatalf("Error applying migration %s: %v", migration.Name, err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	unlock, err := lockMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksums(applied); err != nil {
+		return err
+	}
+
+	// Apply pending migrations up to and including version
+	for _, migration := range migrations {
+		if migration.Version > version {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			if err := applyMigration(ctx, migration); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Roll back applied migrations above version, newest first
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version <= version {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			if err := revertMigration(ctx, migration); err != nil {
+				return err
+			}
 		}
-		log.Printf("Migration %s applied successfully", migration.Name)
-		recordMigration(migration.Name)
 	}
+	return nil
 }
 
-// hasMigrationBeenApplied checks if a migration has already been applied
-func hasMigrationBeenApplied(name string) bool {
-	query := `SELECT COUNT(*) FROM migrations WHERE name = ?`
-	var count int
-	err := DB.QueryRow(query, name).Scan(&count)
+// migrationLock names the MySQL advisory lock held while migrating, and
+// migrationLockWait how long to wait for another process to release it
+const (
+	migrationLock     = "spacevoyagers_migrations"
+	migrationLockWait = 10 * time.Minute
+)
+
+// lockMigrations takes a database-wide lock so that replicas migrating at
+// startup take turns; the ones that wait then find nothing left to apply.
+// SQLite serialises writers itself and is served by a single process.
+func lockMigrations(ctx context.Context) (func(), error) {
+	if Dialect == "sqlite" {
+		return func() {}, nil
+	}
+
+	// The lock belongs to a session, so hold one connection until unlocked
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, migrationLock, int(migrationLockWait.Seconds())).Scan(&acquired)
+	if err == nil && acquired.Int64 != 1 {
+		err = fmt.Errorf("another process held the migration lock for over %s", migrationLockWait)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("taking the migration lock: %w", err)
+	}
+
+	return func() {
+		var released sql.NullInt64
+		if err := conn.QueryRowContext(context.Background(), `SELECT RELEASE_LOCK(?)`, migrationLock).Scan(&released); err != nil {
+			log.Printf("Error releasing the migration lock: %v", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// CurrentVersion returns the highest applied migration version, or 0
+func CurrentVersion() (int, error) {
+	ctx, span := tracer.Start(context.Background(), "CurrentVersion")
+	defer span.End()
+
+	applied, err := appliedMigrations(ctx)
 	if err != nil {
-		// If the `migrations` table doesn't exist, create it.
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1146 {
-			log.Println("Migrations table doesn't exist. Creating it now...")
-			createMigrationsTable()
-			return false
+		return 0, err
+	}
+
+	current := 0
+	for version := range applied {
+		if version > current {
+			current = version
 		}
-		log.Fatalf("Error checking migration status: %v", err)
 	}
-	return count > 0
+	return current, nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 when no
+// migration has run. Unlike CurrentVersion it only reads, so it is safe for
+// health checks.
+func SchemaVersion(ctx context.Context) (int, error) {
+	if DB == nil {
+		return 0, errors.New("database connection is not initialized")
+	}
+
+	var version int
+	err := DB.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil && isMissingTable(err) {
+		return 0, nil
+	}
+	return version, err
 }
 
-// recordMigration marks a migration as applied by adding it to the `migrations` table
-func recordMigration(name string) {
-	query := `INSERT INTO migrations (name) VALUES (?)`
-	_, err := DB.Exec(query, name)
+// Status lists every known migration and whether it has been applied
+func Status() ([]MigrationStatus, error) {
+	ctx, span := tracer.Start(context.Background(), "MigrationStatus")
+	defer span.End()
+
+	applied, err := appliedMigrations(ctx)
 	if err != nil {
-		log.Fatalf("Error recording migration %s: %v", name, err)
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		statuses[i] = MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = record.appliedAt
+			statuses[i].Modified = record.checksum != migration.Checksum()
+		}
 	}
+	return statuses, nil
 }
 
-// createMigrationsTable creates the migrations table if it doesn't exist
-func createMigrationsTable() {
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	checksum  string
+	appliedAt string
+}
+
+// appliedMigrations returns the bookkeeping rows keyed by version, creating
+// the table on first use
+func appliedMigrations(ctx context.Context) (map[int]appliedMigration, error) {
+	if DB == nil {
+		return nil, errors.New("database connection is not initialized")
+	}
+	if err := createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	if err := importLegacyMigrations(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("reading migration status: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var checksum string
+		var appliedAt sql.NullString
+		if err := rows.Scan(&version, &checksum, &appliedAt); err != nil {
+			return nil, fmt.Errorf("reading migration status: %w", err)
+		}
+		applied[version] = appliedMigration{checksum: checksum, appliedAt: appliedAt.String}
+	}
+	return applied, rows.Err()
+}
+
+// verifyChecksums refuses to migrate when an applied migration was edited
+func verifyChecksums(applied map[int]appliedMigration) error {
+	for _, migration := range migrations {
+		if record, ok := applied[migration.Version]; ok && record.checksum != migration.Checksum() {
+			return fmt.Errorf("migration %d (%s) was modified after it was applied", migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs the Up statements of a migration and records it, in one
+// transaction. MySQL commits DDL implicitly, so there the transaction only
+// guarantees the bookkeeping row is not written when a statement fails.
+func applyMigration(ctx context.Context, migration Migration) error {
+	ctx, span := tracer.Start(ctx, "ApplyMigration")
+	span.SetAttributes(attribute.Int("migration.version", migration.Version), attribute.String("migration.name", migration.Name))
+	defer span.End()
+
+	err := inTransaction(ctx, func(tx *sql.Tx) error {
+		skip := false
+		if migration.Applied != nil {
+			present, err := migration.Applied(ctx, tx)
+			if err != nil {
+				return err
+			}
+			skip = present
+		}
+		if !skip {
+			if err := execStatements(ctx, tx, migration.up()); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`,
+			migration.Version, migration.Name, migration.Checksum())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("applying migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+	log.Printf("Migration %d %s applied successfully", migration.Version, migration.Name)
+	return nil
+}
+
+// revertMigration runs the Down statements of a migration and removes its
+// bookkeeping row, in one transaction
+func revertMigration(ctx context.Context, migration Migration) error {
+	ctx, span := tracer.Start(ctx, "RevertMigration")
+	span.SetAttributes(attribute.Int("migration.version", migration.Version), attribute.String("migration.name", migration.Name))
+	defer span.End()
+
+	err := inTransaction(ctx, func(tx *sql.Tx) error {
+		skip := false
+		if migration.Applied != nil {
+			present, err := migration.Applied(ctx, tx)
+			if err != nil {
+				return err
+			}
+			skip = !present
+		}
+		if !skip {
+			if err := execStatements(ctx, tx, migration.down()); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("rolling back migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+	log.Printf("Migration %d %s rolled back successfully", migration.Version, migration.Name)
+	return nil
+}
+
+// inTransaction runs fn in a transaction, committing only if it succeeds
+func inTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// execStatements runs each semicolon-separated statement of query. Migration
+// SQL must therefore not contain semicolons inside literals.
+func execStatements(ctx context.Context, tx *sql.Tx, query string) error {
+	for _, statement := range strings.Split(query, ";") {
+		if strings.TrimSpace(statement) == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findMigration returns the migration with the given version, or nil
+func findMigration(version int) *Migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
+
+// columnExists reports whether table has the named column
+func columnExists(ctx context.Context, tx *sql.Tx, table, column string) (bool, error) {
+	query := `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`
+	if Dialect == "sqlite" {
+		query = `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, query, table, column).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// isMissingTable reports whether err was caused by querying a table that does not exist
+func isMissingTable(err error) bool {
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1146 {
+		return true
+	}
+	return Dialect == "sqlite" && strings.Contains(err.Error(), "no such table")
+}
+
+// createMigrationsTable creates the schema_migrations table if it doesn't exist
+func createMigrationsTable(ctx context.Context) error {
 	query := `
-        CREATE TABLE IF NOT EXISTS migrations (
-            id INT AUTO_INCREMENT,
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INT NOT NULL PRIMARY KEY,
             name VARCHAR(255) NOT NULL,
-            applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-            PRIMARY KEY (id)
+            checksum CHAR(64) NOT NULL,
+            applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
         );
     `
-	_, err := DB.Exec(query)
+	if _, err := DB.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("creating schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// importLegacyMigrations carries over the name-based `migrations` table used
+// before migrations were versioned, so existing databases are not migrated twice
+func importLegacyMigrations(ctx context.Context) error {
+	var count int
+	if err := DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		return fmt.Errorf("reading migration status: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	rows, err := DB.QueryContext(ctx, `SELECT name FROM migrations`)
 	if err != nil {
-		log.Fatalf("Error creating migrations table: %v", err)
+		if isMissingTable(err) {
+			return nil
+		}
+		return fmt.Errorf("reading legacy migrations: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("reading legacy migrations: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+
+	for _, name := range names {
+		for _, migration := range migrations {
+			if migration.Name != name {
+				continue
+			}
+			_, err := DB.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`,
+				migration.Version, migration.Name, migration.Checksum())
+			if err != nil {
+				return fmt.Errorf("importing legacy migration %s: %w", name, err)
+			}
+			log.Printf("Migration %d %s imported from the legacy migrations table", migration.Version, migration.Name)
+		}
 	}
+	return nil
 }
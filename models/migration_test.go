@@ -0,0 +1,108 @@
+package models
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+// useSQLite points the migrations at a throwaway SQLite database
+func useSQLite(t *testing.T) *sql.DB {
+	dbConn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { dbConn.Close() })
+
+	SetDB(dbConn)
+	SetDialect("sqlite")
+	return dbConn
+}
+
+// TestMigrateUpDownTo walks the schema forwards and backwards
+func TestMigrateUpDownTo(t *testing.T) {
+	useSQLite(t)
+
+	require.NoError(t, MigrateUp())
+	version, err := CurrentVersion()
+	require.NoError(t, err)
+	assert.Equal(t, LatestVersion(), version)
+
+	// Re-running is a no-op
+	require.NoError(t, MigrateUp())
+
+	require.NoError(t, MigrateDown())
+	version, err = CurrentVersion()
+	require.NoError(t, err)
+	assert.Equal(t, LatestVersion()-1, version)
+
+	require.NoError(t, MigrateTo(0))
+	statuses, err := Status()
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.False(t, status.Applied, status.Name)
+	}
+
+	assert.Error(t, MigrateDown())
+	assert.Error(t, MigrateTo(LatestVersion()+1))
+}
+
+// TestMigrateLegacyDatabase upgrades a database migrated by name only, where
+// the gravity and version columns exist but re-adding them would fail
+func TestMigrateLegacyDatabase(t *testing.T) {
+	dbConn := useSQLite(t)
+
+	for _, statement := range []string{
+		`CREATE TABLE migrations (id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`,
+		`INSERT INTO migrations (name) VALUES ('create_exoplanets_table')`,
+		migrations[0].SQLiteUp,
+		`ALTER TABLE exoplanets ADD COLUMN gravity FLOAT DEFAULT NULL`,
+		`ALTER TABLE exoplanets ADD COLUMN version INT NOT NULL DEFAULT 1`,
+	} {
+		_, err := dbConn.Exec(statement)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, MigrateUp())
+
+	statuses, err := Status()
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.True(t, status.Applied, status.Name)
+		assert.False(t, status.Modified, status.Name)
+	}
+}
+
+// TestMigrateRejectsModifiedMigration refuses to run when history was edited
+func TestMigrateRejectsModifiedMigration(t *testing.T) {
+	dbConn := useSQLite(t)
+	require.NoError(t, MigrateUp())
+
+	_, err := dbConn.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1`)
+	require.NoError(t, err)
+
+	statuses, err := Status()
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Modified)
+	assert.Error(t, MigrateTo(0))
+}
+
+// TestMigrateFillsGravity computes the gravity of rows stored without it
+func TestMigrateFillsGravity(t *testing.T) {
+	dbConn := useSQLite(t)
+	require.NoError(t, MigrateTo(8))
+
+	_, err := dbConn.Exec(`INSERT INTO exoplanets (name, description, distance, radius, mass, type) VALUES ('Legacy', 'Old row', 10, 2, 4, 'Terrestrial')`)
+	require.NoError(t, err)
+	require.NoError(t, MigrateUp())
+
+	var gravity sql.NullFloat64
+	var version int
+	require.NoError(t, dbConn.QueryRow(`SELECT gravity, version FROM exoplanets`).Scan(&gravity, &version))
+	require.True(t, gravity.Valid)
+	legacy := Exoplanet{Radius: 2, Mass: 4}
+	assert.InDelta(t, legacy.CalculateGravity(), gravity.Float64, 1e-9)
+	assert.Equal(t, 2, version)
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"math"
+
+	"github.com/anilsaini81155/spacevoyagers/units"
+)
+
+// Physical constants and unit sizes in SI units
+const (
+	GravitationalConstant = 6.67430e-11 // m³ kg⁻¹ s⁻²
+
+	EarthMass     = units.EarthMass
+	EarthRadius   = units.EarthRadius
+	JupiterMass   = units.JupiterMass
+	JupiterRadius = units.JupiterRadius
+
+	LightYear = units.LightYear
+)
+
+// Physics holds quantities derived from an exoplanet's mass and radius, all in
+// SI units. It is empty when the mass is unknown.
+type Physics struct {
+	SurfaceGravity  float64 `json:"surface_gravity,omitempty"`  // m/s²
+	EscapeVelocity  float64 `json:"escape_velocity,omitempty"`  // m/s
+	Density         float64 `json:"density,omitempty"`          // kg/m³
+	OrbitalVelocity float64 `json:"orbital_velocity,omitempty"` // m/s, circular orbit at the surface
+	OrbitalPeriod   float64 `json:"orbital_period,omitempty"`   // s, circular orbit at the surface
+}
+
+// MassKg returns the mass in kilograms
+func (p *Exoplanet) MassKg() float64 {
+	return p.Mass * EarthMass
+}
+
+// RadiusMeters returns the radius in metres
+func (p *Exoplanet) RadiusMeters() float64 {
+	return p.Radius * EarthRadius
+}
+
+// DistanceMeters returns the distance from Earth in metres
+func (p *Exoplanet) DistanceMeters() float64 {
+	return p.Distance * LightYear
+}
+
+// EscapeVelocity returns the surface escape velocity in m/s, √(2GM/R)
+func (p *Exoplanet) EscapeVelocity() float64 {
+	return math.Sqrt(2 * GravitationalConstant * p.MassKg() / p.RadiusMeters())
+}
+
+// Density returns the mean density in kg/m³
+func (p *Exoplanet) Density() float64 {
+	radius := p.RadiusMeters()
+	return p.MassKg() / (4.0 / 3.0 * math.Pi * radius * radius * radius)
+}
+
+// CircularOrbit returns the speed (m/s) and period (s) of a circular orbit at
+// the given altitude in metres above the surface
+func (p *Exoplanet) CircularOrbit(altitude float64) (velocity, period float64) {
+	r := p.RadiusMeters() + altitude
+	mu := GravitationalConstant * p.MassKg()
+	velocity = math.Sqrt(mu / r)
+	period = 2 * math.Pi * math.Sqrt(r*r*r/mu)
+	return velocity, period
+}
+
+// Physics returns the derived physical quantities of the exoplanet, or none
+// when its mass is unknown, as for gas giants stored before mass was required
+func (p *Exoplanet) Physics() Physics {
+	if p.Mass <= 0 || p.Radius <= 0 {
+		return Physics{}
+	}
+	velocity, period := p.CircularOrbit(0)
+	return Physics{
+		SurfaceGravity:  p.CalculateGravity(),
+		EscapeVelocity:  p.EscapeVelocity(),
+		Density:         p.Density(),
+		OrbitalVelocity: velocity,
+		OrbitalPeriod:   period,
+	}
+}
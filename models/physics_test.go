@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPhysicsMatchesKnownPlanets checks the derived quantities against Earth and Jupiter
+func TestPhysicsMatchesKnownPlanets(t *testing.T) {
+	earth := Exoplanet{Radius: 1, Mass: 1, Type: Terrestrial}
+	physics := earth.Physics()
+	assert.InDelta(t, 9.82, physics.SurfaceGravity, 0.01)
+	assert.InDelta(t, 11186, physics.EscapeVelocity, 5)
+	assert.InDelta(t, 5513, physics.Density, 5)
+	assert.InDelta(t, 7910, physics.OrbitalVelocity, 5)
+
+	jupiter := Exoplanet{Radius: JupiterRadius / EarthRadius, Mass: JupiterMass / EarthMass, Type: GasGiant}
+	physics = jupiter.Physics()
+	assert.InDelta(t, 25.9, physics.SurfaceGravity, 0.1)
+	assert.InDelta(t, 60200, physics.EscapeVelocity, 50)
+	assert.InDelta(t, 1326, physics.Density, 5)
+	assert.Equal(t, "jupiter_mass", jupiter.Units(Astro).Mass)
+}
+
+// TestValidateRequiresMass checks that gas giants now need a real mass too
+func TestValidateRequiresMass(t *testing.T) {
+	giant := Exoplanet{Name: "Giant", Description: "No mass", Distance: 10, Radius: 1, Type: GasGiant}
+	assert.EqualError(t, giant.Validate(), "mass: required for gasgiant exoplanets")
+
+	giant.Mass = 0.8
+	assert.NoError(t, giant.Validate())
+}
+
+// TestPhysicsWithoutMass derives nothing, rather than infinities, from an
+// exoplanet whose mass is unknown
+func TestPhysicsWithoutMass(t *testing.T) {
+	legacy := Exoplanet{Distance: 20, Radius: 121, Type: GasGiant}
+	assert.Equal(t, Physics{}, legacy.Physics())
+
+	_, err := legacy.FuelEstimation(5)
+	assert.ErrorIs(t, err, ErrValidation)
+}
@@ -0,0 +1,42 @@
+package models
+
+// SpeedOfLight bounds the cruise speed of a ship
+const SpeedOfLight = 299792458.0 // m/s
+
+// Ship is a catalogued vehicle that missions can be planned for. Masses are
+// in kg, SpecificImpulse in seconds and CruiseSpeed in m/s.
+type Ship struct {
+	ID              int     `json:"id"`
+	Name            string  `json:"name"`
+	DryMass         float64 `json:"dry_mass"`
+	FuelCapacity    float64 `json:"fuel_capacity"`
+	SpecificImpulse float64 `json:"specific_impulse"`
+	MaxCrew         int     `json:"max_crew"`
+	CruiseSpeed     float64 `json:"cruise_speed"`
+	PayloadPerCrew  float64 `json:"payload_per_crew"`
+}
+
+// Validate ensures that the ship details are correct
+func (s *Ship) Validate() error {
+	var errs ValidationError
+	checkText(&errs, "name", s.Name, MaxNameLength, false)
+	if s.DryMass <= 0 {
+		errs.Add("dry_mass", CodeOutOfRange, "must be positive")
+	}
+	if s.FuelCapacity <= 0 {
+		errs.Add("fuel_capacity", CodeOutOfRange, "must be positive")
+	}
+	if s.SpecificImpulse <= 0 {
+		errs.Add("specific_impulse", CodeOutOfRange, "must be positive")
+	}
+	if s.MaxCrew < 1 {
+		errs.Add("max_crew", CodeOutOfRange, "must be at least 1")
+	}
+	if s.CruiseSpeed <= 0 || s.CruiseSpeed >= SpeedOfLight {
+		errs.Add("cruise_speed", CodeOutOfRange, "must be between 0 and the speed of light")
+	}
+	if s.PayloadPerCrew < 0 {
+		errs.Add("payload_per_crew", CodeOutOfRange, "cannot be negative")
+	}
+	return errs.Err()
+}
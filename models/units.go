@@ -0,0 +1,191 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/anilsaini81155/spacevoyagers/units"
+)
+
+// UnitSystem selects the units exoplanets are represented in over the API
+type UnitSystem string
+
+const (
+	// Astro uses light-years, with Earth units for terrestrial planets and
+	// Jupiter units for gas giants. It is the default.
+	Astro UnitSystem = "astro"
+	// SI uses metres and kilograms
+	SI UnitSystem = "si"
+)
+
+// Canonical storage units
+const (
+	storageDistanceUnit = "ly"
+	storageRadiusUnit   = "earth_radius"
+	storageMassUnit     = "earth_mass"
+)
+
+// StorageUnits are the units exoplanets are stored in, and so filtered and
+// sorted by, whatever their type
+var StorageUnits = Units{Distance: storageDistanceUnit, Radius: storageRadiusUnit, Mass: storageMassUnit, Gravity: "m/s2"}
+
+// ParseUnitSystem parses the units query parameter; empty means Astro
+func ParseUnitSystem(s string) (UnitSystem, error) {
+	switch UnitSystem(s) {
+	case "", Astro:
+		return Astro, nil
+	case SI:
+		return SI, nil
+	}
+	return "", InvalidField("units", CodeInvalidChoice, fmt.Sprintf("must be %q or %q", Astro, SI))
+}
+
+// Units names the unit of each dimensioned field of an exoplanet
+type Units struct {
+	Distance string `json:"distance"`
+	Radius   string `json:"radius"`
+	Mass     string `json:"mass"`
+	Gravity  string `json:"gravity"`
+}
+
+// Units returns the units the exoplanet is represented in for a unit system
+func (p *Exoplanet) Units(system UnitSystem) Units {
+	if system == SI {
+		return Units{Distance: "m", Radius: "m", Mass: "kg", Gravity: "m/s2"}
+	}
+	if p.Type == GasGiant {
+		return Units{Distance: "ly", Radius: "jupiter_radius", Mass: "jupiter_mass", Gravity: "m/s2"}
+	}
+	return Units{Distance: "ly", Radius: "earth_radius", Mass: "earth_mass", Gravity: "m/s2"}
+}
+
+// View is the JSON representation of an exoplanet in one unit system, with
+// the units and derived physics spelled out
+type View struct {
+	ID          int           `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Distance    float64       `json:"distance"`
+	Radius      float64       `json:"radius"`
+	Mass        float64       `json:"mass,omitempty"`
+	Type        ExoplanetType `json:"type"`
+	Gravity     float64       `json:"gravity"`
+	Version     int           `json:"version"`
+	// MassUnknown flags exoplanets stored without a mass (see Exoplanet.Mass)
+	MassUnknown bool    `json:"mass_unknown,omitempty"`
+	Units       Units   `json:"units"`
+	Physics     Physics `json:"physics"`
+}
+
+// View converts the exoplanet from storage units into a unit system
+func (p Exoplanet) View(system UnitSystem) View {
+	u := p.Units(system)
+	distance, _ := units.ConvertLength(p.Distance, storageDistanceUnit, u.Distance)
+	radius, _ := units.ConvertLength(p.Radius, storageRadiusUnit, u.Radius)
+	mass, _ := units.ConvertMass(p.Mass, storageMassUnit, u.Mass)
+
+	return View{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Distance:    distance,
+		Radius:      radius,
+		Mass:        mass,
+		Type:        p.Type,
+		Gravity:     p.Gravity,
+		Version:     p.Version,
+		MassUnknown: p.Mass <= 0,
+		Units:       u,
+		Physics:     p.Physics(),
+	}
+}
+
+// Document is the writable form of an exoplanet that PATCH requests apply to.
+// Quantities carry their unit, so that they keep their meaning when a patch
+// changes the type; values patched in as bare numbers are in the requested
+// unit system.
+type Document struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Distance    units.Quantity  `json:"distance"`
+	Radius      units.Quantity  `json:"radius"`
+	Mass        *units.Quantity `json:"mass,omitempty"`
+	Type        ExoplanetType   `json:"type"`
+}
+
+// Document returns the writable form of the exoplanet in a unit system
+func (p Exoplanet) Document(system UnitSystem) Document {
+	view := p.View(system)
+	doc := Document{
+		Name:        view.Name,
+		Description: view.Description,
+		Distance:    units.Quantity{Value: view.Distance, Unit: view.Units.Distance},
+		Radius:      units.Quantity{Value: view.Radius, Unit: view.Units.Radius},
+		Type:        view.Type,
+	}
+	if p.Mass != 0 {
+		doc.Mass = &units.Quantity{Value: view.Mass, Unit: view.Units.Mass}
+	}
+	return doc
+}
+
+// MarshalJSON represents the exoplanet in Astro units
+func (p Exoplanet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.View(Astro))
+}
+
+// UnmarshalJSON reads an exoplanet into storage units. Distance, radius and
+// mass may be bare numbers in Astro units for the exoplanet's type, or
+// {"value": ..., "unit": ...} objects in any unit of the right dimension.
+// Gravity is derived and therefore ignored.
+func (p *Exoplanet) UnmarshalJSON(data []byte) error {
+	return p.UnmarshalIn(data, Astro)
+}
+
+// UnmarshalIn reads an exoplanet like UnmarshalJSON, with bare numbers in the
+// units of system for the exoplanet's type
+func (p *Exoplanet) UnmarshalIn(data []byte, system UnitSystem) error {
+	var raw struct {
+		ID          int             `json:"id"`
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Distance    *units.Quantity `json:"distance"`
+		Radius      *units.Quantity `json:"radius"`
+		Mass        *units.Quantity `json:"mass"`
+		Type        ExoplanetType   `json:"type"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*p = Exoplanet{ID: raw.ID, Name: raw.Name, Description: raw.Description, Type: raw.Type}
+	defaults := p.Units(system)
+
+	var err error
+	if p.Distance, err = toStorage(raw.Distance, "distance", defaults.Distance, storageDistanceUnit, units.ConvertLength); err != nil {
+		return err
+	}
+	if p.Radius, err = toStorage(raw.Radius, "radius", defaults.Radius, storageRadiusUnit, units.ConvertLength); err != nil {
+		return err
+	}
+	if p.Mass, err = toStorage(raw.Mass, "mass", defaults.Mass, storageMassUnit, units.ConvertMass); err != nil {
+		return err
+	}
+	return nil
+}
+
+// toStorage converts an optional quantity into the storage unit
+func toStorage(q *units.Quantity, field, defaultUnit, storageUnit string, convert func(float64, string, string) (float64, error)) (float64, error) {
+	if q == nil {
+		return 0, nil
+	}
+	unit := q.Unit
+	if unit == "" {
+		unit = defaultUnit
+	}
+	value, err := convert(q.Value, unit, storageUnit)
+	if err != nil {
+		return 0, InvalidField(field, CodeInvalid, err.Error())
+	}
+	return value, nil
+}
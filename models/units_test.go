@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExoplanetJSONUnits checks that quantities are read into storage units and written back per unit system
+func TestExoplanetJSONUnits(t *testing.T) {
+	var giant Exoplanet
+	body := `{"name":"Giant","description":"Units","distance":{"value":2,"unit":"pc"},"radius":1,"mass":{"value":317.8276012189813,"unit":"earth_mass"},"type":"GasGiant","gravity":99}`
+	require.NoError(t, json.Unmarshal([]byte(body), &giant))
+	assert.InDelta(t, 6.5231, giant.Distance, 1e-3)
+	assert.InDelta(t, JupiterRadius/EarthRadius, giant.Radius, 1e-9)
+	assert.InDelta(t, JupiterMass/EarthMass, giant.Mass, 1e-9)
+	assert.Zero(t, giant.Gravity)
+
+	astro := giant.View(Astro)
+	assert.InDelta(t, 1, astro.Radius, 1e-9)
+	assert.InDelta(t, 1, astro.Mass, 1e-9)
+	assert.Equal(t, "jupiter_radius", astro.Units.Radius)
+
+	si := giant.View(SI)
+	assert.InDelta(t, JupiterRadius, si.Radius, 1)
+	assert.InDelta(t, 2*3.0856775814913673e16, si.Distance, 1e3)
+	assert.Equal(t, "kg", si.Units.Mass)
+
+	err := json.Unmarshal([]byte(`{"radius":{"value":1,"unit":"kg"}}`), &giant)
+	assert.EqualError(t, err, `radius: "kg" is not a length unit`)
+
+	_, err = ParseUnitSystem("imperial")
+	assert.Error(t, err)
+}
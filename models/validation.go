@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Column sizes that bound text fields
+const (
+	// MaxNameLength is the size in characters of the VARCHAR(255) name columns
+	MaxNameLength = 255
+	// MaxDescriptionLength is the size in bytes of the TEXT description column
+	MaxDescriptionLength = 65535
+)
+
+// Range bounds a quantity in Unit: it must be above Min and at most Max
+type Range struct {
+	Min, Max float64
+	Unit     string
+}
+
+// ExoplanetRules are the ranges an exoplanet's quantities must fall in
+type ExoplanetRules struct {
+	Distance Range
+	Radius   Range
+	Mass     Range
+}
+
+// exoplanetRules declares the rules of each exoplanet type. The bounds are
+// loose enough for any catalogued planet and catch values sent in the wrong
+// unit, such as a gas giant's radius in Earth radii.
+var exoplanetRules = map[ExoplanetType]ExoplanetRules{
+	Terrestrial: {
+		Distance: Range{Max: 100000, Unit: "ly"},
+		Radius:   Range{Max: 3, Unit: "earth_radius"},
+		Mass:     Range{Max: 25, Unit: "earth_mass"},
+	},
+	GasGiant: {
+		Distance: Range{Max: 100000, Unit: "ly"},
+		Radius:   Range{Max: 3, Unit: "jupiter_radius"},
+		Mass:     Range{Max: 80, Unit: "jupiter_mass"},
+	},
+}
+
+// RulesFor returns the rules of an exoplanet type, and false when the type is
+// unknown
+func RulesFor(t ExoplanetType) (ExoplanetRules, bool) {
+	rules, ok := exoplanetRules[t]
+	return rules, ok
+}
+
+// checkText records a missing or over-long text field. Lengths are counted
+// in characters when inBytes is false.
+func checkText(errs *ValidationError, field, value string, max int, inBytes bool) {
+	length := utf8.RuneCountInString(value)
+	if inBytes {
+		length = len(value)
+	}
+	switch {
+	case strings.TrimSpace(value) == "":
+		errs.Add(field, CodeRequired, "is required")
+	case length > max:
+		unit := "characters"
+		if inBytes {
+			unit = "bytes"
+		}
+		errs.Add(field, CodeTooLong, fmt.Sprintf("must be at most %d %s", max, unit))
+	}
+}
+
+// checkQuantity records a value, held in storage unit, that is not finite or
+// falls outside r
+func checkQuantity(errs *ValidationError, field string, value float64, storage string, r Range, convert func(float64, string, string) (float64, error)) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		errs.Add(field, CodeNotFinite, "must be a finite number")
+		return
+	}
+	if r.Unit != "" {
+		value, _ = convert(value, storage, r.Unit)
+	}
+	switch {
+	case value <= r.Min && r.Min == 0:
+		errs.Add(field, CodeOutOfRange, "must be positive")
+	case value <= r.Min:
+		errs.Add(field, CodeOutOfRange, fmt.Sprintf("must be above %g %s", r.Min, r.Unit))
+	case r.Max > 0 && value > r.Max:
+		errs.Add(field, CodeOutOfRange, fmt.Sprintf("must be at most %g %s", r.Max, r.Unit))
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExoplanetValidationRules checks that every violated rule is reported
+// with its field and code, and that ranges follow the type's units
+func TestExoplanetValidationRules(t *testing.T) {
+	valid := func(planetType ExoplanetType) Exoplanet {
+		return Exoplanet{Name: "Kepler-22b", Description: "Habitable zone", Distance: 600, Radius: 2.4, Mass: 9, Type: planetType}
+	}
+
+	earthLike := valid(Terrestrial)
+	require.NoError(t, earthLike.Validate())
+
+	for _, tt := range []struct {
+		name   string
+		modify func(p *Exoplanet)
+		fields []FieldError
+	}{
+		{"blank name", func(p *Exoplanet) { p.Name = "   " }, []FieldError{{"name", CodeRequired, "is required"}}},
+		{"long name", func(p *Exoplanet) { p.Name = strings.Repeat("é", MaxNameLength+1) }, []FieldError{{"name", CodeTooLong, "must be at most 255 characters"}}},
+		{"unknown type", func(p *Exoplanet) { p.Type = "IceGiant" }, []FieldError{{"type", CodeInvalidChoice, "must be Terrestrial or GasGiant"}}},
+		{"infinite distance", func(p *Exoplanet) { p.Distance = math.Inf(1) }, []FieldError{{"distance", CodeNotFinite, "must be a finite number"}}},
+		{"NaN radius", func(p *Exoplanet) { p.Radius = math.NaN() }, []FieldError{{"radius", CodeNotFinite, "must be a finite number"}}},
+		{"beyond the galaxy", func(p *Exoplanet) { p.Distance = 2e5 }, []FieldError{{"distance", CodeOutOfRange, "must be at most 100000 ly"}}},
+		{"several", func(p *Exoplanet) { p.Radius = 30; p.Mass = -1 }, []FieldError{
+			{"radius", CodeOutOfRange, "must be at most 3 earth_radius"},
+			{"mass", CodeOutOfRange, "must be positive"},
+		}},
+	} {
+		p := valid(Terrestrial)
+		tt.modify(&p)
+		var errs *ValidationError
+		require.ErrorAs(t, p.Validate(), &errs, tt.name)
+		assert.Equal(t, tt.fields, errs.Fields, tt.name)
+	}
+
+	// A gas giant of 2.4 Earth radii is valid, but not one of 30 Jupiter radii
+	giant := valid(GasGiant)
+	require.NoError(t, giant.Validate())
+	giant.Radius = 30 * JupiterRadius / EarthRadius
+	assert.EqualError(t, giant.Validate(), "radius: must be at most 3 jupiter_radius")
+}
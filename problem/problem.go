@@ -0,0 +1,83 @@
+// Package problem writes error responses as RFC 7807 problem details
+// (application/problem+json), the one error format of the API.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/anilsaini81155/spacevoyagers/logging"
+	"github.com/anilsaini81155/spacevoyagers/models"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// Details is the body of a problem response. Type is always "about:blank",
+// so Title is the HTTP status text; Detail explains this occurrence.
+type Details struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// RequestID correlates the response with the server's logs
+	RequestID string `json:"request_id,omitempty"`
+	// Errors lists the invalid fields of a validation failure
+	Errors []models.FieldError `json:"errors,omitempty"`
+}
+
+// New returns the problem details of a response to r with status
+func New(r *http.Request, status int, detail string) Details {
+	return Details{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: logging.RequestID(r.Context()),
+	}
+}
+
+// Write responds with a problem of the given status
+func Write(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	WriteDetails(w, New(r, status, detail))
+}
+
+// WriteDetails responds with details
+func WriteDetails(w http.ResponseWriter, details Details) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(details.Status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(details)
+}
+
+// Error responds with the status matching err: 400 for models.ErrValidation,
+// with the invalid fields when err is a *models.ValidationError, 404 for
+// models.ErrNotFound, 409 for models.ErrConflict and 412 for models.ErrStale.
+// Any other error is logged and answered with a 500 that does not reveal it.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	var validation *models.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		details := New(r, http.StatusBadRequest, err.Error())
+		details.Errors = validation.Fields
+		WriteDetails(w, details)
+	case errors.Is(err, models.ErrValidation):
+		Write(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, models.ErrNotFound):
+		Write(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, models.ErrConflict):
+		Write(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, models.ErrStale):
+		Write(w, r, http.StatusPreconditionFailed, err.Error())
+	default:
+		slog.ErrorContext(r.Context(), "Error handling request", "method", r.Method, "path", r.URL.Path, "error", err)
+		Write(w, r, http.StatusInternalServerError, "The server could not complete the request; quote the request ID when reporting it.")
+	}
+}
@@ -0,0 +1,48 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anilsaini81155/spacevoyagers/logging"
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestErrorStatus checks that each kind of error gets its status code and
+// that internal errors are not revealed
+func TestErrorStatus(t *testing.T) {
+	for _, tt := range []struct {
+		err    error
+		status int
+		detail string
+	}{
+		{models.InvalidField("radius", models.CodeOutOfRange, "must be positive"), http.StatusBadRequest, "radius: must be positive"},
+		{fmt.Errorf("ship %w", models.ErrNotFound), http.StatusNotFound, "ship not found"},
+		{fmt.Errorf("%w: name is taken", models.ErrConflict), http.StatusConflict, "conflict: name is taken"},
+		{errors.New("connection refused"), http.StatusInternalServerError, "quote the request ID"},
+	} {
+		req := httptest.NewRequest("GET", "/ships/7", nil)
+		req = req.WithContext(logging.WithRequestID(req.Context(), "abc123"))
+		rr := httptest.NewRecorder()
+		Error(rr, req, tt.err)
+
+		assert.Equal(t, tt.status, rr.Code)
+		assert.Equal(t, ContentType, rr.Header().Get("Content-Type"))
+		assert.NotContains(t, rr.Body.String(), "connection refused")
+
+		var details Details
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &details))
+		assert.Equal(t, "about:blank", details.Type)
+		assert.Equal(t, http.StatusText(tt.status), details.Title)
+		assert.Equal(t, tt.status, details.Status)
+		assert.Contains(t, details.Detail, tt.detail)
+		assert.Equal(t, "/ships/7", details.Instance)
+		assert.Equal(t, "abc123", details.RequestID)
+	}
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+)
+
+// ErrAPIKeyNotFound is returned when no API key matches
+var ErrAPIKeyNotFound = fmt.Errorf("api key %w", models.ErrNotFound)
+
+// sqlAPIKeyRepository stores API keys in MySQL or SQLite
+type sqlAPIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewSQLAPIKeys returns an API key repository backed by a MySQL or SQLite database
+func NewSQLAPIKeys(db *sql.DB) APIKeyRepository {
+	return &sqlAPIKeyRepository{db: db}
+}
+
+const selectAPIKeys = `SELECT id, name, prefix, key_hash, role, created_at, revoked_at FROM api_keys`
+
+// Create inserts a new API key into the database
+func (r *sqlAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	query := `INSERT INTO api_keys (name, prefix, key_hash, role, created_at) VALUES (?, ?, ?, ?, ?)`
+	result, err := r.db.ExecContext(ctx, query, key.Name, key.Prefix, key.Hash, key.Role, key.CreatedAt.Unix())
+	if err != nil {
+		return duplicate(err)
+	}
+	id, _ := result.LastInsertId()
+	key.ID = int(id)
+	return nil
+}
+
+// GetByHash retrieves the API key whose secret hashes to hash, revoked or not
+func (r *sqlAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := scanAPIKey(r.db.QueryRowContext(ctx, selectAPIKeys+` WHERE key_hash = ?`, hash), &key)
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// List retrieves every API key ordered by id
+func (r *sqlAPIKeyRepository) List(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, selectAPIKeys+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var key models.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke marks an API key as revoked. Revoking a revoked key keeps the
+// original revocation time.
+func (r *sqlAPIKeyRepository) Revoke(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now().Unix(), id)
+	if err := affected(result, err, ErrAPIKeyNotFound); err != ErrAPIKeyNotFound {
+		return err
+	}
+	// Nothing changed: either the key is missing or it was already revoked
+	return exists(ctx, r.db, "api_keys", id, ErrAPIKeyNotFound)
+}
+
+// scanAPIKey reads the columns of selectAPIKeys into key
+func scanAPIKey(row scanner, key *models.APIKey) error {
+	var createdAt int64
+	var revokedAt sql.NullInt64
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &key.Role, &createdAt, &revokedAt); err != nil {
+		return err
+	}
+	key.CreatedAt = time.Unix(createdAt, 0).UTC()
+	if revokedAt.Valid {
+		t := time.Unix(revokedAt.Int64, 0).UTC()
+		key.RevokedAt = &t
+	}
+	return nil
+}
+
+// memoryAPIKeyRepository keeps API keys in process memory
+type memoryAPIKeyRepository struct {
+	mu     sync.RWMutex
+	keys   map[int]models.APIKey
+	nextID int
+}
+
+// NewMemoryAPIKeys returns an empty in-memory API key repository
+func NewMemoryAPIKeys() APIKeyRepository {
+	return &memoryAPIKeyRepository{keys: make(map[int]models.APIKey), nextID: 1}
+}
+
+// Create stores a new API key and assigns its ID
+func (r *memoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key.ID = r.nextID
+	r.nextID++
+	r.keys[key.ID] = *key
+	return nil
+}
+
+// GetByHash retrieves the API key whose secret hashes to hash, revoked or not
+func (r *memoryAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			return &key, nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+// List retrieves every API key ordered by id
+func (r *memoryAPIKeyRepository) List(ctx context.Context) ([]models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var keys []models.APIKey
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+// Revoke marks an API key as revoked
+func (r *memoryAPIKeyRepository) Revoke(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now().UTC().Truncate(time.Second)
+		key.RevokedAt = &now
+		r.keys[id] = key
+	}
+	return nil
+}
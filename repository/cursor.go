@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+)
+
+// Cursor marks the last row of a page so the next page can resume after it
+// (keyset pagination on the sort fields plus id). Clients only ever see it as
+// an opaque string.
+type Cursor struct {
+	Sort   string        `json:"s,omitempty"`
+	Values []interface{} `json:"v,omitempty"`
+	ID     int           `json:"id"`
+}
+
+// NewCursor returns the cursor positioned on exoplanet for the given sort
+func NewCursor(sort Sort, exoplanet models.Exoplanet) Cursor {
+	cursor := Cursor{Sort: sort.String(), ID: exoplanet.ID}
+	for _, key := range sort {
+		cursor.Values = append(cursor.Values, sortFields[key.Field].value(exoplanet))
+	}
+	return cursor
+}
+
+// Encode returns the opaque string form of the cursor
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses an opaque cursor and checks it was issued for sort
+func DecodeCursor(encoded string, sort Sort) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	if cursor.Sort != sort.String() {
+		return nil, errors.New("cursor was issued for a different sort order")
+	}
+	if len(cursor.Values) != len(sort) {
+		return nil, errors.New("invalid cursor")
+	}
+
+	// Each value must have the JSON type of its sort field
+	for i, key := range sort {
+		var ok bool
+		if sortFields[key.Field].text {
+			_, ok = cursor.Values[i].(string)
+		} else {
+			_, ok = cursor.Values[i].(float64)
+		}
+		if !ok {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+	return &cursor, nil
+}
+
+// keyset returns the SQL condition selecting rows after the cursor:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... OR (k1 = v1 AND ... AND id > id0),
+// with < in place of > for descending keys
+func (c *Cursor) keyset(sort Sort) (string, []interface{}) {
+	var alternatives []string
+	var args []interface{}
+
+	for i := 0; i <= len(sort); i++ {
+		var terms []string
+		for j := 0; j < i; j++ {
+			terms = append(terms, sortFields[sort[j].Field].column+" = ?")
+			args = append(args, c.Values[j])
+		}
+
+		if i == len(sort) {
+			terms = append(terms, "id > ?")
+			args = append(args, c.ID)
+		} else {
+			op := " > ?"
+			if sort[i].Desc {
+				op = " < ?"
+			}
+			terms = append(terms, sortFields[sort[i].Field].column+op)
+			args = append(args, c.Values[i])
+		}
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// compareTo orders exoplanet relative to the cursor position using the same
+// rules as the SQL keyset condition
+func (c *Cursor) compareTo(sort Sort, exoplanet models.Exoplanet) int {
+	for i, key := range sort {
+		if comparison := compareValues(sortFields[key.Field].value(exoplanet), c.Values[i]); comparison != 0 {
+			if key.Desc {
+				return -comparison
+			}
+			return comparison
+		}
+	}
+	return cmp.Compare(exoplanet.ID, c.ID)
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+)
+
+// memoryRepository keeps exoplanets in process memory. It is intended for
+// local development and tests; data is lost when the process exits.
+type memoryRepository struct {
+	mu         sync.RWMutex
+	exoplanets map[int]models.Exoplanet
+	nextID     int
+}
+
+// NewMemory returns an empty in-memory repository
+func NewMemory() ExoplanetRepository {
+	return &memoryRepository{
+		exoplanets: make(map[int]models.Exoplanet),
+		nextID:     1,
+	}
+}
+
+// Create stores a new exoplanet and assigns its ID
+func (r *memoryRepository) Create(ctx context.Context, exoplanet *models.Exoplanet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exoplanet.Gravity = exoplanet.CalculateGravity()
+	exoplanet.ID = r.nextID
+	exoplanet.Version = 1
+	r.nextID++
+	r.exoplanets[exoplanet.ID] = *exoplanet
+	return nil
+}
+
+// GetByID retrieves a specific exoplanet by its ID
+func (r *memoryRepository) GetByID(ctx context.Context, id int) (*models.Exoplanet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	exoplanet, ok := r.exoplanets[id]
+	if !ok {
+		return nil, ErrExoplanetNotFound
+	}
+	return &exoplanet, nil
+}
+
+// Update replaces an existing exoplanet and increments its version
+func (r *memoryRepository) Update(ctx context.Context, exoplanet *models.Exoplanet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exoplanet.Gravity = exoplanet.CalculateGravity()
+
+	current, ok := r.exoplanets[exoplanet.ID]
+	if !ok {
+		return ErrExoplanetNotFound
+	}
+	if exoplanet.Version != 0 && exoplanet.Version != current.Version {
+		return ErrExoplanetChanged
+	}
+	exoplanet.Version = current.Version + 1
+	r.exoplanets[exoplanet.ID] = *exoplanet
+	return nil
+}
+
+// Delete removes an exoplanet
+func (r *memoryRepository) Delete(ctx context.Context, id int, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.exoplanets[id]
+	if !ok {
+		return ErrExoplanetNotFound
+	}
+	if version != 0 && version != current.Version {
+		return ErrExoplanetChanged
+	}
+	delete(r.exoplanets, id)
+	return nil
+}
+
+// List retrieves the exoplanets matching opts
+func (r *memoryRepository) List(ctx context.Context, opts ListOptions) ([]models.Exoplanet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	exoplanets := r.filter(opts)
+
+	slices.SortFunc(exoplanets, opts.Sort.compare)
+
+	// Resume after the cursor position
+	if opts.After != nil {
+		start := sort.Search(len(exoplanets), func(i int) bool {
+			return opts.After.compareTo(opts.Sort, exoplanets[i]) > 0
+		})
+		exoplanets = exoplanets[start:]
+	}
+
+	if opts.Limit > 0 && len(exoplanets) > opts.Limit {
+		exoplanets = exoplanets[:opts.Limit]
+	}
+	return exoplanets, nil
+}
+
+// Count returns the number of exoplanets matching the filter in opts
+func (r *memoryRepository) Count(ctx context.Context, opts ListOptions) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.filter(opts)), nil
+}
+
+// filter returns the exoplanets matching the filter in opts. The caller must
+// hold the lock.
+func (r *memoryRepository) filter(opts ListOptions) []models.Exoplanet {
+	var exoplanets []models.Exoplanet
+	for _, exoplanet := range r.exoplanets {
+		if opts.Filter != nil && !opts.Filter.Match(exoplanet) {
+			continue
+		}
+		exoplanets = append(exoplanets, exoplanet)
+	}
+	return exoplanets
+}
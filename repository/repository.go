@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/anilsaini81155/spacevoyagers/db"
+	"github.com/anilsaini81155/spacevoyagers/filter"
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Errors returned when a record does not exist; each matches models.ErrNotFound
+var (
+	ErrExoplanetNotFound = fmt.Errorf("exoplanet %w", models.ErrNotFound)
+	ErrShipNotFound      = fmt.Errorf("ship %w", models.ErrNotFound)
+)
+
+// ErrExoplanetChanged is returned by conditional writes to an exoplanet whose
+// version has moved on; it matches models.ErrStale
+var ErrExoplanetChanged = fmt.Errorf("exoplanet %w", models.ErrStale)
+
+// duplicate reports err as a models.ErrConflict when it was caused by a
+// unique constraint, and returns it unchanged otherwise
+func duplicate(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if (errors.As(err, &mysqlErr) && mysqlErr.Number == 1062) || (err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")) {
+		return fmt.Errorf("%w: %v", models.ErrConflict, err)
+	}
+	return err
+}
+
+// affected returns notFound when a statement affected no row. MySQL counts
+// the rows an UPDATE changed, so an update that may leave its row as it was
+// must confirm a notFound with exists.
+func affected(result sql.Result, err error, notFound error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// exists returns notFound unless table has a row with the given id. MySQL
+// counts the rows an UPDATE changed rather than those it matched, so this
+// tells a missing row from one an update left as it was.
+func exists(ctx context.Context, db *sql.DB, table string, id int, notFound error) error {
+	var count int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+` WHERE id = ?`, id).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// ExoplanetRepository abstracts the storage of exoplanets so handlers do not
+// depend on a particular database
+type ExoplanetRepository interface {
+	Create(ctx context.Context, exoplanet *models.Exoplanet) error
+	GetByID(ctx context.Context, id int) (*models.Exoplanet, error)
+	// Update and Delete return ErrExoplanetNotFound when the ID does not
+	// exist. Update increments the version; when exoplanet.Version, or the
+	// version passed to Delete, is not 0 the write only applies to that
+	// version and otherwise fails with ErrExoplanetChanged.
+	Update(ctx context.Context, exoplanet *models.Exoplanet) error
+	Delete(ctx context.Context, id int, version int) error
+	List(ctx context.Context, opts ListOptions) ([]models.Exoplanet, error)
+	Count(ctx context.Context, opts ListOptions) (int, error)
+}
+
+// ShipRepository abstracts the storage of the ship catalog
+type ShipRepository interface {
+	Create(ctx context.Context, ship *models.Ship) error
+	GetByID(ctx context.Context, id int) (*models.Ship, error)
+	// Update and Delete return ErrShipNotFound when the ID does not exist
+	Update(ctx context.Context, ship *models.Ship) error
+	Delete(ctx context.Context, id int) error
+	List(ctx context.Context) ([]models.Ship, error)
+}
+
+// APIKeyRepository abstracts the storage of issued API keys
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	GetByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	List(ctx context.Context) ([]models.APIKey, error)
+	Revoke(ctx context.Context, id int) error
+}
+
+// Repositories groups the repositories of one storage backend
+type Repositories struct {
+	Exoplanets ExoplanetRepository
+	Ships      ShipRepository
+	APIKeys    APIKeyRepository
+}
+
+// GravityBackfiller is implemented by backends that store gravity and may
+// hold rows written before it was computed
+type GravityBackfiller interface {
+	BackfillGravity(ctx context.Context) (int, error)
+}
+
+// ListOptions holds the filter, sort order and page bounds applied when
+// listing exoplanets. Nil or empty fields are not applied. Count only honours
+// the filter.
+type ListOptions struct {
+	Filter filter.Expr
+	Sort   Sort
+	After  *Cursor
+	Limit  int
+}
+
+// Open creates the repositories for the given driver ("mysql", "sqlite" or
+// "memory"). SQL drivers share the db.GetDB singleton; their schema is not
+// migrated here, see models.MigrateUp.
+func Open(driver string) (*Repositories, error) {
+	switch driver {
+	case db.DriverMemory:
+		return &Repositories{Exoplanets: NewMemory(), Ships: NewMemoryShips(), APIKeys: NewMemoryAPIKeys()}, nil
+	case db.DriverMySQL, db.DriverSQLite:
+		dbConn, err := db.GetDB()
+		if err != nil {
+			return nil, err
+		}
+
+		models.SetDB(dbConn)
+		models.SetDialect(driver)
+
+		repos := &Repositories{Ships: NewSQLShips(dbConn), APIKeys: NewSQLAPIKeys(dbConn)}
+		if driver == db.DriverSQLite {
+			repos.Exoplanets = NewSQLite(dbConn)
+		} else {
+			repos.Exoplanets = NewMySQL(dbConn)
+		}
+		return repos, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
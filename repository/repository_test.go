@@ -0,0 +1,346 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anilsaini81155/spacevoyagers/filter"
+	"github.com/anilsaini81155/spacevoyagers/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite opens a throwaway SQLite database with migrations applied
+func openSQLite(t *testing.T) *sql.DB {
+	dbConn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { dbConn.Close() })
+
+	models.SetDB(dbConn)
+	models.SetDialect("sqlite")
+	require.NoError(t, models.RunMigrations())
+	return dbConn
+}
+
+// openMySQL opens the MySQL database named by MYSQL_TEST_DSN (e.g.
+// "user:password@tcp(localhost:3306)/exoplanets_test") with migrations applied
+// and its tables emptied. The test is skipped when it is not set.
+func openMySQL(t *testing.T) *sql.DB {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_TEST_DSN is not set")
+	}
+	dbConn, err := sql.Open("mysql", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { dbConn.Close() })
+
+	models.SetDB(dbConn)
+	models.SetDialect("mysql")
+	require.NoError(t, models.RunMigrations())
+	for _, table := range []string{"exoplanets", "ships", "api_keys"} {
+		_, err = dbConn.Exec(`DELETE FROM ` + table)
+		require.NoError(t, err)
+	}
+	return dbConn
+}
+
+// newSQLiteRepository opens a throwaway SQLite database with migrations applied
+func newSQLiteRepository(t *testing.T) ExoplanetRepository {
+	return NewSQLite(openSQLite(t))
+}
+
+// newMySQLRepository opens the MySQL test database, see openMySQL
+func newMySQLRepository(t *testing.T) ExoplanetRepository {
+	return NewMySQL(openMySQL(t))
+}
+
+// TestRepositories runs the same scenario against every backend; MySQL only
+// when MYSQL_TEST_DSN is set
+func TestRepositories(t *testing.T) {
+	backends := map[string]func(t *testing.T) ExoplanetRepository{
+		"memory": func(t *testing.T) ExoplanetRepository { return NewMemory() },
+		"sqlite": newSQLiteRepository,
+		"mysql":  newMySQLRepository,
+	}
+
+	for name, newRepo := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+
+			planets := []models.Exoplanet{
+				{Name: "Kepler-22b", Description: "Earth-like", Distance: 600, Radius: 2.4, Mass: 5.9, Type: models.Terrestrial},
+				{Name: "Jupiter-like", Description: "Gas giant", Distance: 1200, Radius: 12.07, Mass: 31.78, Type: models.GasGiant},
+				{Name: "Proxima b", Description: "Nearby", Distance: 4.2, Radius: 1.1, Mass: 1.2, Type: models.Terrestrial},
+			}
+			for i := range planets {
+				require.NoError(t, repo.Create(ctx, &planets[i]))
+				assert.NotZero(t, planets[i].ID)
+			}
+
+			got, err := repo.GetByID(ctx, planets[0].ID)
+			require.NoError(t, err)
+			assert.Equal(t, planets[0], *got)
+
+			expr, err := filter.Parse("type eq 'Terrestrial' and distance ge 100")
+			require.NoError(t, err)
+			list, err := repo.List(ctx, ListOptions{Filter: expr})
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, "Kepler-22b", list[0].Name)
+
+			byDistance := Sort{{Field: "distance"}}
+			list, err = repo.List(ctx, ListOptions{Sort: byDistance})
+			require.NoError(t, err)
+			require.Len(t, list, 3)
+			assert.Equal(t, []string{"Proxima b", "Kepler-22b", "Jupiter-like"}, []string{list[0].Name, list[1].Name, list[2].Name})
+
+			cursor := NewCursor(byDistance, list[0])
+			list, err = repo.List(ctx, ListOptions{Sort: byDistance, After: &cursor, Limit: 1})
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, "Kepler-22b", list[0].Name)
+
+			// Multi-key sort with a descending key, resumed from a cursor
+			byTypeThenFarthest, err := ParseSort("type,-distance")
+			require.NoError(t, err)
+			list, err = repo.List(ctx, ListOptions{Sort: byTypeThenFarthest})
+			require.NoError(t, err)
+			require.Len(t, list, 3)
+			assert.Equal(t, []string{"Jupiter-like", "Kepler-22b", "Proxima b"}, []string{list[0].Name, list[1].Name, list[2].Name})
+
+			cursor = NewCursor(byTypeThenFarthest, list[1])
+			list, err = repo.List(ctx, ListOptions{Sort: byTypeThenFarthest, After: &cursor})
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, "Proxima b", list[0].Name)
+
+			// Computed fields sort the same way in every backend
+			byGravity, err := ParseSort("-gravity")
+			require.NoError(t, err)
+			list, err = repo.List(ctx, ListOptions{Sort: byGravity})
+			require.NoError(t, err)
+			require.Len(t, list, 3)
+			assert.Equal(t, []string{"Kepler-22b", "Proxima b", "Jupiter-like"}, []string{list[0].Name, list[1].Name, list[2].Name})
+
+			expr, err = filter.Parse("name prefix 'kepler' or gravity gt 9")
+			require.NoError(t, err)
+			count, err := repo.Count(ctx, ListOptions{Filter: expr})
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+
+			// Text equality ignores case in every backend
+			expr, err = filter.Parse("name eq 'PROXIMA B' or type in ('gasgiant')")
+			require.NoError(t, err)
+			count, err = repo.Count(ctx, ListOptions{Filter: expr})
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+			expr, err = filter.Parse("name ne 'kepler-22B'")
+			require.NoError(t, err)
+			count, err = repo.Count(ctx, ListOptions{Filter: expr})
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+
+			planets[1].Description = "Updated"
+			require.NoError(t, repo.Update(ctx, &planets[1]))
+			got, err = repo.GetByID(ctx, planets[1].ID)
+			require.NoError(t, err)
+			assert.Equal(t, "Updated", got.Description)
+			assert.Equal(t, 2, planets[1].Version)
+			assert.Equal(t, 2, got.Version)
+
+			// An update that changes nothing still finds its row
+			require.NoError(t, repo.Update(ctx, &planets[1]))
+			assert.Equal(t, 3, planets[1].Version)
+
+			// Conditional writes only apply to the current version
+			stale := *got
+			assert.ErrorIs(t, repo.Update(ctx, &stale), models.ErrStale)
+			assert.ErrorIs(t, repo.Delete(ctx, planets[1].ID, 2), models.ErrStale)
+			require.NoError(t, repo.Update(ctx, &planets[1]))
+			assert.Equal(t, 4, planets[1].Version)
+
+			require.NoError(t, repo.Delete(ctx, planets[2].ID, 0))
+			_, err = repo.GetByID(ctx, planets[2].ID)
+			assert.Error(t, err)
+			assert.ErrorIs(t, repo.Update(ctx, &planets[2]), models.ErrNotFound)
+			assert.ErrorIs(t, repo.Delete(ctx, planets[2].ID, 0), models.ErrNotFound)
+		})
+	}
+}
+
+// TestPaginationTies pages one row at a time through exoplanets tied on the
+// sort keys, with values that single precision cannot represent, and expects
+// every row exactly once in the unpaged order
+func TestPaginationTies(t *testing.T) {
+	backends := map[string]func(t *testing.T) ExoplanetRepository{
+		"memory": func(t *testing.T) ExoplanetRepository { return NewMemory() },
+		"sqlite": newSQLiteRepository,
+		"mysql":  newMySQLRepository,
+	}
+
+	for name, newRepo := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+
+			for i, distance := range []float64{4.2, 12.3, 4.2, 4.2, 12.3} {
+				planet := models.Exoplanet{Name: fmt.Sprintf("Planet %d", i), Description: "Tied", Distance: distance, Radius: 1.1, Mass: 1.3, Type: models.Terrestrial}
+				require.NoError(t, repo.Create(ctx, &planet))
+			}
+			// Gas giants stored without a mass have a gravity of 0
+			for i := 0; i < 2; i++ {
+				planet := models.Exoplanet{Name: fmt.Sprintf("Giant %d", i), Description: "No mass", Distance: 8, Radius: 121, Type: models.GasGiant}
+				require.NoError(t, repo.Create(ctx, &planet))
+			}
+
+			for _, spec := range []string{"distance", "-distance", "radius,-mass", "-gravity", "fuel", "type,-fuel"} {
+				sort, err := ParseSort(spec)
+				require.NoError(t, err)
+
+				all, err := repo.List(ctx, ListOptions{Sort: sort})
+				require.NoError(t, err)
+				require.Len(t, all, 7)
+				if spec == "fuel" {
+					assert.Equal(t, []string{"Giant 0", "Giant 1"}, []string{all[5].Name, all[6].Name}, "unknown fuel sorts last")
+				}
+
+				var paged []int
+				var after *Cursor
+				for len(paged) <= len(all) {
+					page, err := repo.List(ctx, ListOptions{Sort: sort, After: after, Limit: 1})
+					require.NoError(t, err)
+					if len(page) == 0 {
+						break
+					}
+					paged = append(paged, page[0].ID)
+					after, err = DecodeCursor(NewCursor(sort, page[0]).Encode(), sort)
+					require.NoError(t, err)
+				}
+
+				var ids []int
+				for _, planet := range all {
+					ids = append(ids, planet.ID)
+				}
+				assert.Equal(t, ids, paged, spec)
+			}
+		})
+	}
+}
+
+// TestShipRepositories checks that writes to a missing ship fail with
+// ErrShipNotFound while a write that changes nothing succeeds
+func TestShipRepositories(t *testing.T) {
+	backends := map[string]func(t *testing.T) ShipRepository{
+		"memory": func(t *testing.T) ShipRepository { return NewMemoryShips() },
+		"sqlite": func(t *testing.T) ShipRepository { return NewSQLShips(openSQLite(t)) },
+		"mysql":  func(t *testing.T) ShipRepository { return NewSQLShips(openMySQL(t)) },
+	}
+
+	for name, newRepo := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+
+			ship := models.Ship{Name: "Endurance", DryMass: 100000, FuelCapacity: 900000, SpecificImpulse: 8000, MaxCrew: 8, CruiseSpeed: 30000}
+			require.NoError(t, repo.Create(ctx, &ship))
+			require.NoError(t, repo.Update(ctx, &ship))
+
+			missing := ship
+			missing.ID = ship.ID + 1000
+			assert.ErrorIs(t, repo.Update(ctx, &missing), ErrShipNotFound)
+
+			require.NoError(t, repo.Delete(ctx, ship.ID))
+			assert.ErrorIs(t, repo.Delete(ctx, ship.ID), ErrShipNotFound)
+			assert.ErrorIs(t, repo.Update(ctx, &ship), ErrShipNotFound)
+		})
+	}
+}
+
+// TestAPIKeyRepositories checks that revoking a key twice keeps the first
+// revocation time and that revoking a missing key fails
+func TestAPIKeyRepositories(t *testing.T) {
+	backends := map[string]func(t *testing.T) APIKeyRepository{
+		"memory": func(t *testing.T) APIKeyRepository { return NewMemoryAPIKeys() },
+		"sqlite": func(t *testing.T) APIKeyRepository { return NewSQLAPIKeys(openSQLite(t)) },
+		"mysql":  func(t *testing.T) APIKeyRepository { return NewSQLAPIKeys(openMySQL(t)) },
+	}
+
+	for name, newRepo := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+
+			key, _, err := models.NewAPIKey("ci", models.RoleEditor)
+			require.NoError(t, err)
+			require.NoError(t, repo.Create(ctx, &key))
+
+			require.NoError(t, repo.Revoke(ctx, key.ID))
+			revoked, err := repo.GetByHash(ctx, key.Hash)
+			require.NoError(t, err)
+			require.NotNil(t, revoked.RevokedAt)
+
+			require.NoError(t, repo.Revoke(ctx, key.ID))
+			again, err := repo.GetByHash(ctx, key.Hash)
+			require.NoError(t, err)
+			assert.Equal(t, revoked.RevokedAt, again.RevokedAt)
+
+			assert.ErrorIs(t, repo.Revoke(ctx, key.ID+1000), ErrAPIKeyNotFound)
+		})
+	}
+}
+
+// TestBackfillGravity stores gravity for rows inserted before it was computed
+func TestBackfillGravity(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+
+	_, err := models.DB.Exec(`INSERT INTO exoplanets (name, description, distance, radius, mass, type) VALUES ('Legacy', 'Old row', 10, 2, 4, 'Terrestrial')`)
+	require.NoError(t, err)
+
+	// Served correctly, but invisible to gravity filters until backfilled
+	list, err := repo.List(ctx, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.InDelta(t, 9.82, list[0].Gravity, 0.01)
+
+	expr, err := filter.Parse("gravity gt 9")
+	require.NoError(t, err)
+	count, err := repo.Count(ctx, ListOptions{Filter: expr})
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	updated, err := repo.(GravityBackfiller).BackfillGravity(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated)
+
+	count, err = repo.Count(ctx, ListOptions{Filter: expr})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	updated, err = repo.(GravityBackfiller).BackfillGravity(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, updated)
+}
+
+// TestParseSort checks the accepted and rejected sort expressions
+func TestParseSort(t *testing.T) {
+	sort, err := ParseSort("type, -distance,id")
+	require.NoError(t, err)
+	assert.Equal(t, Sort{{Field: "type"}, {Field: "distance", Desc: true}}, sort)
+	assert.Equal(t, "type,-distance", sort.String())
+
+	sort, err = ParseSort("")
+	require.NoError(t, err)
+	assert.Empty(t, sort)
+
+	for _, invalid := range []string{"colour", "name,-name", "-id", "id,name", "name,,radius"} {
+		_, err := ParseSort(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+)
+
+// sqlShipRepository stores ships in MySQL or SQLite
+type sqlShipRepository struct {
+	db *sql.DB
+}
+
+// NewSQLShips returns a ship repository backed by a MySQL or SQLite database
+func NewSQLShips(db *sql.DB) ShipRepository {
+	return &sqlShipRepository{db: db}
+}
+
+const selectShips = `SELECT id, name, dry_mass, fuel_capacity, specific_impulse, max_crew, cruise_speed, payload_per_crew FROM ships`
+
+// Create inserts a new ship into the database
+func (r *sqlShipRepository) Create(ctx context.Context, ship *models.Ship) error {
+	query := `INSERT INTO ships (name, dry_mass, fuel_capacity, specific_impulse, max_crew, cruise_speed, payload_per_crew) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.ExecContext(ctx, query, ship.Name, ship.DryMass, ship.FuelCapacity, ship.SpecificImpulse, ship.MaxCrew, ship.CruiseSpeed, ship.PayloadPerCrew)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	ship.ID = int(id)
+	return nil
+}
+
+// GetByID retrieves a specific ship by its ID
+func (r *sqlShipRepository) GetByID(ctx context.Context, id int) (*models.Ship, error) {
+	var ship models.Ship
+	err := scanShip(r.db.QueryRowContext(ctx, selectShips+` WHERE id = ?`, id), &ship)
+	if err == sql.ErrNoRows {
+		return nil, ErrShipNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &ship, nil
+}
+
+// Update updates an existing ship in the database
+func (r *sqlShipRepository) Update(ctx context.Context, ship *models.Ship) error {
+	query := `UPDATE ships SET name = ?, dry_mass = ?, fuel_capacity = ?, specific_impulse = ?, max_crew = ?, cruise_speed = ?, payload_per_crew = ? WHERE id = ?`
+	result, err := r.db.ExecContext(ctx, query, ship.Name, ship.DryMass, ship.FuelCapacity, ship.SpecificImpulse, ship.MaxCrew, ship.CruiseSpeed, ship.PayloadPerCrew, ship.ID)
+	if err := affected(result, err, ErrShipNotFound); err != ErrShipNotFound {
+		return err
+	}
+	// Nothing changed: either the ship is missing or it was already as sent
+	return exists(ctx, r.db, "ships", ship.ID, ErrShipNotFound)
+}
+
+// Delete removes a ship from the database
+func (r *sqlShipRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM ships WHERE id = ?`, id)
+	return affected(result, err, ErrShipNotFound)
+}
+
+// List retrieves every ship ordered by id
+func (r *sqlShipRepository) List(ctx context.Context) ([]models.Ship, error) {
+	rows, err := r.db.QueryContext(ctx, selectShips+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ships []models.Ship
+	for rows.Next() {
+		var ship models.Ship
+		if err := scanShip(rows, &ship); err != nil {
+			return nil, err
+		}
+		ships = append(ships, ship)
+	}
+	return ships, rows.Err()
+}
+
+// scanShip reads the columns of selectShips into ship
+func scanShip(row scanner, ship *models.Ship) error {
+	return row.Scan(&ship.ID, &ship.Name, &ship.DryMass, &ship.FuelCapacity, &ship.SpecificImpulse, &ship.MaxCrew, &ship.CruiseSpeed, &ship.PayloadPerCrew)
+}
+
+// memoryShipRepository keeps ships in process memory
+type memoryShipRepository struct {
+	mu     sync.RWMutex
+	ships  map[int]models.Ship
+	nextID int
+}
+
+// NewMemoryShips returns an empty in-memory ship repository
+func NewMemoryShips() ShipRepository {
+	return &memoryShipRepository{ships: make(map[int]models.Ship), nextID: 1}
+}
+
+// Create stores a new ship and assigns its ID
+func (r *memoryShipRepository) Create(ctx context.Context, ship *models.Ship) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ship.ID = r.nextID
+	r.nextID++
+	r.ships[ship.ID] = *ship
+	return nil
+}
+
+// GetByID retrieves a specific ship by its ID
+func (r *memoryShipRepository) GetByID(ctx context.Context, id int) (*models.Ship, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ship, ok := r.ships[id]
+	if !ok {
+		return nil, ErrShipNotFound
+	}
+	return &ship, nil
+}
+
+// Update replaces an existing ship
+func (r *memoryShipRepository) Update(ctx context.Context, ship *models.Ship) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.ships[ship.ID]; !ok {
+		return ErrShipNotFound
+	}
+	r.ships[ship.ID] = *ship
+	return nil
+}
+
+// Delete removes a ship
+func (r *memoryShipRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.ships[id]; !ok {
+		return ErrShipNotFound
+	}
+	delete(r.ships, id)
+	return nil
+}
+
+// List retrieves every ship ordered by id
+func (r *memoryShipRepository) List(ctx context.Context) ([]models.Ship, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ships []models.Ship
+	for _, ship := range r.ships {
+		ships = append(ships, ship)
+	}
+	sort.Slice(ships, func(i, j int) bool { return ships[i].ID < ships[j].ID })
+	return ships, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+)
+
+// SortKey is one field of a sort order
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Sort is an ordered list of sort keys. Rows are always finally ordered by
+// ascending id so that pages are stable; an empty Sort orders by id only.
+type Sort []SortKey
+
+// sortField describes how to order by an exoplanet attribute
+type sortField struct {
+	column string // SQL expression
+	text   bool   // string valued rather than numeric
+	value  func(p models.Exoplanet) interface{}
+}
+
+// sortFields lists every attribute that can be sorted on
+var sortFields = map[string]sortField{
+	"name":        {column: "name", text: true, value: func(p models.Exoplanet) interface{} { return p.Name }},
+	"description": {column: "description", text: true, value: func(p models.Exoplanet) interface{} { return p.Description }},
+	"distance":    {column: "distance", value: func(p models.Exoplanet) interface{} { return p.Distance }},
+	"radius":      {column: "radius", value: func(p models.Exoplanet) interface{} { return p.Radius }},
+	"mass":        {column: "mass", value: func(p models.Exoplanet) interface{} { return p.Mass }},
+	"type":        {column: "type", text: true, value: func(p models.Exoplanet) interface{} { return string(p.Type) }},
+	"gravity":     {column: "gravity", value: func(p models.Exoplanet) interface{} { return p.Gravity }},
+	// fuel orders by the fuel needed per crew member, which gives the same
+	// order as FuelEstimation for any crew size. Cursors hold the value
+	// computed from the stored gravity, which compares equal to the SQL
+	// expression; ties are broken by id.
+	"fuel": {column: models.FuelPerCrewSQL, value: func(p models.Exoplanet) interface{} { return p.FuelPerCrew() }},
+}
+
+// ParseSort parses a comma-separated list of fields, each optionally
+// prefixed with - for descending order, e.g. "type,-distance"
+func ParseSort(s string) (Sort, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var sort Sort
+	seen := map[string]bool{}
+	parts := strings.Split(s, ",")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		key := SortKey{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+
+		if key.Field == "id" {
+			// id is always the final key, so it may only be given last and ascending
+			if key.Desc || i != len(parts)-1 {
+				return nil, fmt.Errorf("sort field %q: id can only be the last field, ascending", part)
+			}
+			break
+		}
+		if _, ok := sortFields[key.Field]; !ok {
+			return nil, fmt.Errorf("unknown sort field %q", part)
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("sort field %q is repeated", key.Field)
+		}
+		seen[key.Field] = true
+		sort = append(sort, key)
+	}
+	return sort, nil
+}
+
+// String returns the canonical form of the sort, as accepted by ParseSort
+func (s Sort) String() string {
+	parts := make([]string, len(s))
+	for i, key := range s {
+		if key.Desc {
+			parts[i] = "-" + key.Field
+		} else {
+			parts[i] = key.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// orderBy returns the SQL ORDER BY clause for the sort
+func (s Sort) orderBy() string {
+	terms := make([]string, 0, len(s)+1)
+	for _, key := range s {
+		term := sortFields[key.Field].column
+		if key.Desc {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	return " ORDER BY " + strings.Join(append(terms, "id"), ", ")
+}
+
+// compare orders two exoplanets by the sort, then by id
+func (s Sort) compare(a, b models.Exoplanet) int {
+	for _, key := range s {
+		value := sortFields[key.Field].value
+		if c := compareValues(value(a), value(b)); c != 0 {
+			if key.Desc {
+				return -c
+			}
+			return c
+		}
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// compareValues orders two sort values of the same type
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return cmp.Compare(a, b.(string))
+	}
+	return 0
+}
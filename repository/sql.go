@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+
+	"github.com/anilsaini81155/spacevoyagers/models"
+)
+
+// sqlRepository stores exoplanets in a relational database. The queries it
+// issues are portable between MySQL and SQLite.
+type sqlRepository struct {
+	db *sql.DB
+}
+
+// NewMySQL returns a repository backed by a MySQL connection pool
+func NewMySQL(db *sql.DB) ExoplanetRepository {
+	return &sqlRepository{db: db}
+}
+
+// NewSQLite returns a repository backed by an embedded SQLite database
+func NewSQLite(db *sql.DB) ExoplanetRepository {
+	return &sqlRepository{db: db}
+}
+
+const selectExoplanets = `SELECT id, name, description, distance, radius, mass, type, gravity, version FROM exoplanets`
+
+// backfillBatchSize is the number of rows read per query by BackfillGravity
+const backfillBatchSize = 500
+
+// Create inserts a new exoplanet into the database
+func (r *sqlRepository) Create(ctx context.Context, exoplanet *models.Exoplanet) error {
+	exoplanet.Gravity = exoplanet.CalculateGravity()
+
+	query := `INSERT INTO exoplanets (name, description, distance, radius, mass, type, gravity) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.ExecContext(ctx, query, exoplanet.Name, exoplanet.Description, exoplanet.Distance, exoplanet.Radius, exoplanet.Mass, exoplanet.Type, exoplanet.Gravity)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	exoplanet.ID = int(id)
+	exoplanet.Version = 1
+	return nil
+}
+
+// GetByID retrieves a specific exoplanet by its ID
+func (r *sqlRepository) GetByID(ctx context.Context, id int) (*models.Exoplanet, error) {
+	var exoplanet models.Exoplanet
+	err := scanExoplanet(r.db.QueryRowContext(ctx, selectExoplanets+` WHERE id = ?`, id), &exoplanet)
+	if err == sql.ErrNoRows {
+		return nil, ErrExoplanetNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &exoplanet, nil
+}
+
+// Update updates an existing exoplanet in the database and sets its new
+// version. The version is read back in the same transaction, which holds the
+// row's lock, so that it is the version of this write.
+func (r *sqlRepository) Update(ctx context.Context, exoplanet *models.Exoplanet) error {
+	exoplanet.Gravity = exoplanet.CalculateGravity()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE exoplanets SET name = ?, description = ?, distance = ?, radius = ?, mass = ?, type = ?, gravity = ?, version = version + 1 WHERE id = ?`
+	args := []interface{}{exoplanet.Name, exoplanet.Description, exoplanet.Distance, exoplanet.Radius, exoplanet.Mass, exoplanet.Type, exoplanet.Gravity, exoplanet.ID}
+	if exoplanet.Version != 0 {
+		query += ` AND version = ?`
+		args = append(args, exoplanet.Version)
+	}
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err := affected(result, err, ErrExoplanetNotFound); err != nil {
+		if errors.Is(err, ErrExoplanetNotFound) && exoplanet.Version != 0 {
+			return missingOrChanged(tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM exoplanets WHERE id = ?`, exoplanet.ID))
+		}
+		return err
+	}
+
+	if err := tx.QueryRowContext(ctx, `SELECT version FROM exoplanets WHERE id = ?`, exoplanet.ID).Scan(&exoplanet.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes an exoplanet from the database
+func (r *sqlRepository) Delete(ctx context.Context, id int, version int) error {
+	query := `DELETE FROM exoplanets WHERE id = ?`
+	args := []interface{}{id}
+	if version != 0 {
+		query += ` AND version = ?`
+		args = append(args, version)
+	}
+	result, err := r.db.ExecContext(ctx, query, args...)
+	err = affected(result, err, ErrExoplanetNotFound)
+	if errors.Is(err, ErrExoplanetNotFound) && version != 0 {
+		return missingOrChanged(r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM exoplanets WHERE id = ?`, id))
+	}
+	return err
+}
+
+// missingOrChanged tells why a conditional write matched no row from a count
+// of the rows with its ID
+func missingOrChanged(count *sql.Row) error {
+	var n int
+	if err := count.Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrExoplanetNotFound
+	}
+	return ErrExoplanetChanged
+}
+
+// List retrieves the exoplanets matching opts
+func (r *sqlRepository) List(ctx context.Context, opts ListOptions) ([]models.Exoplanet, error) {
+	where, args := whereClause(opts)
+	query := selectExoplanets + where
+
+	// Resume after the cursor position (keyset on the sort fields plus id)
+	if opts.After != nil {
+		condition, cursorArgs := opts.After.keyset(opts.Sort)
+		query += " AND " + condition
+		args = append(args, cursorArgs...)
+	}
+
+	// Apply sorting
+	query += opts.Sort.orderBy()
+
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exoplanets []models.Exoplanet
+	for rows.Next() {
+		var exoplanet models.Exoplanet
+		if err := scanExoplanet(rows, &exoplanet); err != nil {
+			return nil, err
+		}
+		exoplanets = append(exoplanets, exoplanet)
+	}
+	return exoplanets, rows.Err()
+}
+
+// Count returns the number of exoplanets matching the filter in opts
+func (r *sqlRepository) Count(ctx context.Context, opts ListOptions) (int, error) {
+	where, args := whereClause(opts)
+
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM exoplanets`+where, args...).Scan(&count)
+	return count, err
+}
+
+// whereClause builds the filter part of a query from opts
+func whereClause(opts ListOptions) (string, []interface{}) {
+	if opts.Filter == nil {
+		return " WHERE 1=1", []interface{}{}
+	}
+	condition, args := opts.Filter.SQL()
+	return " WHERE " + condition, args
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanExoplanet reads the columns of selectExoplanets into exoplanet. Rows
+// written before gravity was stored have it computed on the fly until they
+// are backfilled.
+func scanExoplanet(row scanner, exoplanet *models.Exoplanet) error {
+	var gravity sql.NullFloat64
+	if err := row.Scan(&exoplanet.ID, &exoplanet.Name, &exoplanet.Description, &exoplanet.Distance, &exoplanet.Radius, &exoplanet.Mass, &exoplanet.Type, &gravity, &exoplanet.Version); err != nil {
+		return err
+	}
+
+	if gravity.Valid {
+		exoplanet.Gravity = gravity.Float64
+	} else {
+		exoplanet.Gravity = exoplanet.CalculateGravity()
+	}
+	return nil
+}
+
+// BackfillGravity stores the computed gravity of every row where it is
+// missing or differs from CalculateGravity, returning the rows updated. Their
+// version is incremented, as the representation changes.
+func (r *sqlRepository) BackfillGravity(ctx context.Context) (int, error) {
+	updated := 0
+	lastID := 0
+	for {
+		rows, err := r.db.QueryContext(ctx, `SELECT id, radius, mass, type, gravity FROM exoplanets WHERE id > ? ORDER BY id LIMIT ?`, lastID, backfillBatchSize)
+		if err != nil {
+			return updated, err
+		}
+
+		type pending struct {
+			id      int
+			gravity float64
+		}
+		var batch []pending
+		read := 0
+		for rows.Next() {
+			var exoplanet models.Exoplanet
+			var stored sql.NullFloat64
+			if err := rows.Scan(&exoplanet.ID, &exoplanet.Radius, &exoplanet.Mass, &exoplanet.Type, &stored); err != nil {
+				rows.Close()
+				return updated, err
+			}
+			read++
+			lastID = exoplanet.ID
+
+			gravity := exoplanet.CalculateGravity()
+			if !stored.Valid || !closeEnough(stored.Float64, gravity) {
+				batch = append(batch, pending{id: exoplanet.ID, gravity: gravity})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, err
+		}
+
+		for _, row := range batch {
+			if _, err := r.db.ExecContext(ctx, `UPDATE exoplanets SET gravity = ?, version = version + 1 WHERE id = ?`, row.gravity, row.id); err != nil {
+				return updated, err
+			}
+			updated++
+		}
+
+		if read < backfillBatchSize {
+			return updated, nil
+		}
+	}
+}
+
+// closeEnough compares floats allowing for the single precision of MySQL FLOAT columns
+func closeEnough(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-6*math.Max(math.Abs(a), math.Abs(b))
+}
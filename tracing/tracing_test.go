@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/XSAM/otelsql"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	_ "modernc.org/sqlite"
+)
+
+// TestRequestAndQuerySpans checks that an incoming traceparent is continued,
+// that the request span is named after its route and that SQL statements
+// issued while serving it become its children
+func TestRequestAndQuerySpans(t *testing.T) {
+	shutdown, err := Setup(context.Background(), "none", "")
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	dbConn, err := otelsql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer dbConn.Close()
+
+	r := mux.NewRouter()
+	r.Use(RouteMiddleware)
+	r.HandleFunc("/exoplanets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var one int
+		if err := dbConn.QueryRowContext(r.Context(), "SELECT 1").Scan(&one); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+
+	req := httptest.NewRequest("GET", "/exoplanets/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	Middleware(r).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	spans := exporter.GetSpans()
+	var server *tracetest.SpanStub
+	for i := range spans {
+		if spans[i].SpanKind == trace.SpanKindServer {
+			server = &spans[i]
+		}
+	}
+	require.NotNil(t, server, "no server span recorded")
+	assert.Equal(t, "GET /exoplanets/{id}", server.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+
+	queries := 0
+	for _, span := range spans {
+		if span.SpanKind == trace.SpanKindClient {
+			assert.Equal(t, server.SpanContext.SpanID(), span.Parent.SpanID())
+			queries++
+		}
+	}
+	assert.Positive(t, queries, "no SQL span recorded")
+}
+
+// TestSetupRejectsUnknownExporter checks the exporter name is validated
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), "jaeger", "")
+	assert.Error(t, err)
+}
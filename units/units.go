@@ -0,0 +1,96 @@
+package units
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Unit sizes in SI base units (IAU nominal values where defined)
+const (
+	Metre         = 1.0
+	Kilometre     = 1e3
+	AU            = 1.495978707e11        // m
+	LightYear     = 9.4607304725808e15    // m
+	Parsec        = 3.0856775814913673e16 // m
+	EarthRadius   = 6.3710e6              // m, mean
+	JupiterRadius = 6.9911e7              // m, mean
+
+	Kilogram    = 1.0
+	EarthMass   = 5.9722e24 // kg
+	JupiterMass = 1.89813e27
+)
+
+// Length unit names accepted by ConvertLength
+var lengths = map[string]float64{
+	"m":              Metre,
+	"km":             Kilometre,
+	"au":             AU,
+	"ly":             LightYear,
+	"pc":             Parsec,
+	"earth_radius":   EarthRadius,
+	"jupiter_radius": JupiterRadius,
+}
+
+// Mass unit names accepted by ConvertMass
+var masses = map[string]float64{
+	"kg":           Kilogram,
+	"earth_mass":   EarthMass,
+	"jupiter_mass": JupiterMass,
+}
+
+// ConvertLength converts value between two length units
+func ConvertLength(value float64, from, to string) (float64, error) {
+	return convert(lengths, "length", value, from, to)
+}
+
+// ConvertMass converts value between two mass units
+func ConvertMass(value float64, from, to string) (float64, error) {
+	return convert(masses, "mass", value, from, to)
+}
+
+func convert(table map[string]float64, dimension string, value float64, from, to string) (float64, error) {
+	fromSize, ok := table[from]
+	if !ok {
+		return 0, fmt.Errorf("%q is not a %s unit", from, dimension)
+	}
+	toSize, ok := table[to]
+	if !ok {
+		return 0, fmt.Errorf("%q is not a %s unit", to, dimension)
+	}
+	if from == to {
+		return value, nil
+	}
+	return value * fromSize / toSize, nil
+}
+
+// Quantity is a value with an optional unit. In JSON it is either a bare
+// number, whose unit is implied by the context, or {"value": 600, "unit": "ly"}.
+type Quantity struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+// UnmarshalJSON accepts a bare number or a value/unit object
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' {
+		q.Unit = ""
+		return json.Unmarshal(data, &q.Value)
+	}
+
+	var object struct {
+		Value *float64 `json:"value"`
+		Unit  string   `json:"unit"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	if object.Value == nil {
+		return errors.New("quantity is missing its value")
+	}
+	q.Value = *object.Value
+	q.Unit = object.Unit
+	return nil
+}
@@ -0,0 +1,46 @@
+package units
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConvert checks conversions within and across unit systems
+func TestConvert(t *testing.T) {
+	pc, err := ConvertLength(1, "pc", "ly")
+	require.NoError(t, err)
+	assert.InDelta(t, 3.2616, pc, 1e-4)
+
+	au, err := ConvertLength(1, "ly", "au")
+	require.NoError(t, err)
+	assert.InDelta(t, 63241, au, 1)
+
+	radius, err := ConvertLength(1, "jupiter_radius", "earth_radius")
+	require.NoError(t, err)
+	assert.InDelta(t, 10.973, radius, 1e-3)
+
+	mass, err := ConvertMass(1, "jupiter_mass", "earth_mass")
+	require.NoError(t, err)
+	assert.InDelta(t, 317.83, mass, 1e-2)
+
+	_, err = ConvertLength(1, "kg", "m")
+	assert.Error(t, err)
+	_, err = ConvertMass(1, "earth_mass", "furlong")
+	assert.Error(t, err)
+}
+
+// TestQuantityJSON accepts bare numbers and value/unit objects
+func TestQuantityJSON(t *testing.T) {
+	var q Quantity
+	require.NoError(t, json.Unmarshal([]byte(`600`), &q))
+	assert.Equal(t, Quantity{Value: 600}, q)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"value": 184, "unit": "pc"}`), &q))
+	assert.Equal(t, Quantity{Value: 184, Unit: "pc"}, q)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"unit": "pc"}`), &q))
+	assert.Error(t, json.Unmarshal([]byte(`"far"`), &q))
+}